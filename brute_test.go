@@ -0,0 +1,528 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	. "./cross"
+)
+
+// The brute force here tries every board and checks each rule square by
+// square, without any of the tables or helpers the DP uses, so the two
+// can be checked against each other on boards small enough to try them
+// all. It's shared by count_test.go and genall_test.go, so it only uses
+// what both programs have.
+
+// brute has the board being checked, the way cfg has it, with the
+// squares outside the shape black like the DP stores them
+type brute struct {
+	cfg          Config
+	w, h         int
+	outside      [][]bool
+	wrapX, wrapY bool
+	grid         [][]bool
+}
+
+// bruteBoards gets every board cfg describes that's in b and has at most
+// maxCheaters cheaters, or any number if it's -1. Each one is a row per
+// line with # for black, . for white and a space outside the shape, and
+// they come in string order.
+func bruteBoards(cfg Config, b Bounds, maxCheaters int) []string {
+	br := brute{cfg: cfg, w: cfg.Width, h: cfg.Height, outside: cfg.Shape}
+	if cfg.Shape != nil {
+		br.w, br.h = len(cfg.Shape[0]), len(cfg.Shape)
+	} else {
+		br.outside = make([][]bool, br.h)
+		for i := range br.outside {
+			br.outside[i] = make([]bool, br.w)
+		}
+	}
+	if br.cfg.MinLength == 0 {
+		br.cfg.MinLength = 3
+	}
+	if br.cfg.Components == 0 {
+		br.cfg.Components = 1
+	}
+	br.wrapX = cfg.Topology != Flat
+	br.wrapY = cfg.Topology == Torus
+
+	// squares the symmetry ties together are all black or all white, so
+	// it's enough to try every way of filling in each set of them
+	orbit := make([][]int, br.h)
+	for i := range orbit {
+		orbit[i] = make([]int, br.w)
+		for j := range orbit[i] {
+			orbit[i][j] = -1
+		}
+	}
+	var orbits [][][2]int
+	for i := 0; i < br.h; i++ {
+		for j := 0; j < br.w; j++ {
+			if orbit[i][j] >= 0 || br.outside[i][j] {
+				continue
+			}
+			n := len(orbits)
+			orbit[i][j] = n
+			squares := [][2]int{{i, j}}
+			for k := 0; k < len(squares); k++ {
+				for _, next := range br.images(squares[k][0], squares[k][1]) {
+					if orbit[next[0]][next[1]] < 0 {
+						orbit[next[0]][next[1]] = n
+						squares = append(squares, next)
+					}
+				}
+			}
+			orbits = append(orbits, squares)
+		}
+	}
+
+	br.grid = make([][]bool, br.h)
+	for i := range br.grid {
+		br.grid[i] = make([]bool, br.w)
+	}
+	var boards []string
+	for bits := 0; bits < 1<<len(orbits); bits++ {
+		for i := 0; i < br.h; i++ {
+			for j := 0; j < br.w; j++ {
+				br.grid[i][j] = br.outside[i][j] || bits>>orbit[i][j]&1 == 1
+			}
+		}
+		if !br.valid(b, maxCheaters) {
+			continue
+		}
+		var b strings.Builder
+		for i := range br.grid {
+			if i > 0 {
+				b.WriteByte('\n')
+			}
+			for j := range br.grid[i] {
+				switch {
+				case br.outside[i][j]:
+					b.WriteByte(' ')
+				case br.grid[i][j]:
+					b.WriteByte('#')
+				default:
+					b.WriteByte('.')
+				}
+			}
+		}
+		boards = append(boards, b.String())
+	}
+	sort.Strings(boards)
+	return boards
+}
+
+// entryLengths gets the length of every entry on a board bruteBoards
+// found for cfg
+func entryLengths(cfg Config, board string) []int {
+	lines := strings.Split(board, "\n")
+	br := brute{cfg: cfg, w: len(lines[0]), h: len(lines)}
+	br.wrapX = cfg.Topology != Flat
+	br.wrapY = cfg.Topology == Torus
+	br.grid = make([][]bool, br.h)
+	for i, line := range lines {
+		br.grid[i] = make([]bool, br.w)
+		for j := range line {
+			br.grid[i][j] = line[j] != '.'
+		}
+	}
+	return br.entries()
+}
+
+// images gets where each symmetry of the board sends square (i, j)
+func (br *brute) images(i, j int) [][2]int {
+	w, h := br.w, br.h
+	var ret [][2]int
+	add := func(g Symmetry, a, b int) {
+		if br.cfg.Symmetry&g != 0 {
+			ret = append(ret, [2]int{a, b})
+		}
+	}
+	add(Rot90, j, h-1-i)
+	add(Rot180, h-1-i, w-1-j)
+	add(Rot270, w-1-j, i)
+	add(MirrorLR, i, w-1-j)
+	add(MirrorTB, h-1-i, j)
+	add(Diagonal, j, i)
+	add(AntiDiagonal, w-1-j, h-1-i)
+	return ret
+}
+
+// onBoard says whether square (i, j) is on the board, or past an edge
+// that doesn't wrap around
+func (br *brute) onBoard(i, j int) bool {
+	return (br.wrapY || i >= 0 && i < br.h) && (br.wrapX || j >= 0 && j < br.w)
+}
+
+// lines gets every row and column of the board, and whether it wraps
+func (br *brute) lines() ([][]bool, []bool) {
+	var lines [][]bool
+	var wraps []bool
+	for i := 0; i < br.h; i++ {
+		lines = append(lines, br.grid[i])
+		wraps = append(wraps, br.wrapX)
+	}
+	for j := 0; j < br.w; j++ {
+		col := make([]bool, br.h)
+		for i := range col {
+			col[i] = br.grid[i][j]
+		}
+		lines = append(lines, col)
+		wraps = append(wraps, br.wrapY)
+	}
+	return lines, wraps
+}
+
+// runs gets the length of every run of white squares in line
+func runs(line []bool, wraps bool) []int {
+	start := 0
+	if wraps {
+		// start after a black square, so no run gets cut in two
+		for start < len(line) && !line[start] {
+			start++
+		}
+		if start == len(line) {
+			return []int{len(line)}
+		}
+	}
+	var ret []int
+	run := 0
+	for k := 0; k <= len(line); k++ {
+		if k < len(line) && !line[(start+k)%len(line)] {
+			run++
+			continue
+		}
+		if run > 0 {
+			ret = append(ret, run)
+		}
+		run = 0
+	}
+	return ret
+}
+
+// entries gets every entry on the board
+func (br *brute) entries() []int {
+	var ret []int
+	lines, wraps := br.lines()
+	for k, line := range lines {
+		ret = append(ret, runs(line, wraps[k])...)
+	}
+	return ret
+}
+
+// pieces counts the pieces of squares that are black if black is true or
+// white otherwise. With border, the squares past an edge that doesn't
+// wrap and the ones outside the shape are one more black piece, and the
+// black squares touching them are part of it.
+func (br *brute) pieces(black, border bool) int {
+	seen := make([][]bool, br.h)
+	for i := range seen {
+		seen[i] = make([]bool, br.w)
+	}
+	is := func(i, j int) bool {
+		if br.outside[i][j] {
+			return black && border
+		}
+		return br.grid[i][j] == black
+	}
+	n := 0
+	for i := 0; i < br.h; i++ {
+		for j := 0; j < br.w; j++ {
+			if seen[i][j] || !is(i, j) {
+				continue
+			}
+			touches := false
+			seen[i][j] = true
+			stack := [][2]int{{i, j}}
+			for len(stack) > 0 {
+				a, b := stack[len(stack)-1][0], stack[len(stack)-1][1]
+				stack = stack[:len(stack)-1]
+				if br.outside[a][b] {
+					touches = true
+				}
+				for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					if !br.onBoard(a+d[0], b+d[1]) {
+						touches = true
+						continue
+					}
+					c, e := (a+d[0]+br.h)%br.h, (b+d[1]+br.w)%br.w
+					if !seen[c][e] && is(c, e) {
+						seen[c][e] = true
+						stack = append(stack, [2]int{c, e})
+					}
+				}
+			}
+			if !border || !touches {
+				n++
+			}
+		}
+	}
+	if border {
+		// the border is a piece even if nothing touches it
+		n++
+	}
+	return n
+}
+
+// matches counts where pattern p shows up on the board
+func (br *brute) matches(p Pattern) int {
+	ph, pw := len(p), len(p[0])
+	if ph > br.h || pw > br.w {
+		return 0
+	}
+	lastI, lastJ := br.h-ph, br.w-pw
+	if br.wrapY {
+		lastI = br.h - 1
+	}
+	if br.wrapX {
+		lastJ = br.w - 1
+	}
+	n := 0
+	for top := 0; top <= lastI; top++ {
+		for left := 0; left <= lastJ; left++ {
+			ok := true
+			for a := 0; a < ph && ok; a++ {
+				for b := 0; b < pw && ok; b++ {
+					i, j := (top+a)%br.h, (left+b)%br.w
+					switch p[a][b] {
+					case '#':
+						ok = !br.outside[i][j] && br.grid[i][j]
+					case '.':
+						ok = !br.outside[i][j] && !br.grid[i][j]
+					}
+				}
+			}
+			if ok {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// cheaters counts the black squares that could be white without changing
+// the word count
+func (br *brute) cheaters() int {
+	words := len(br.entries())
+	n := 0
+	for i := 0; i < br.h; i++ {
+		for j := 0; j < br.w; j++ {
+			if br.outside[i][j] || !br.grid[i][j] {
+				continue
+			}
+			br.grid[i][j] = false
+			longEnough := 0
+			for _, run := range br.entries() {
+				if run >= br.cfg.MinLength {
+					longEnough++
+				}
+			}
+			br.grid[i][j] = true
+			if longEnough == words {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// hasCut says whether some white square splits the white squares it's
+// with into more pieces
+func (br *brute) hasCut() bool {
+	pieces := br.pieces(false, false)
+	for i := 0; i < br.h; i++ {
+		for j := 0; j < br.w; j++ {
+			if br.outside[i][j] || br.grid[i][j] {
+				continue
+			}
+			br.grid[i][j] = true
+			more := br.pieces(false, false) > pieces
+			br.grid[i][j] = false
+			if more {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasBlackSide says whether a whole side of a rectangular board is black,
+// leaving out the sides that wrap
+func (br *brute) hasBlackSide() bool {
+	if br.cfg.Shape != nil {
+		return false
+	}
+	allBlack := func(square func(k int) bool, n int) bool {
+		for k := 0; k < n; k++ {
+			if !square(k) {
+				return false
+			}
+		}
+		return true
+	}
+	if !br.wrapY && (allBlack(func(k int) bool { return br.grid[0][k] }, br.w) ||
+		allBlack(func(k int) bool { return br.grid[br.h-1][k] }, br.w)) {
+		return true
+	}
+	return !br.wrapX && (allBlack(func(k int) bool { return br.grid[k][0] }, br.h) ||
+		allBlack(func(k int) bool { return br.grid[k][br.w-1] }, br.h))
+}
+
+// valid checks the board against every rule
+func (br *brute) valid(b Bounds, maxCheaters int) bool {
+	cfg := br.cfg
+	for i, row := range cfg.Partial {
+		for j := range row {
+			if !br.outside[i][j] && row[j] != '?' && (row[j] == '#') != br.grid[i][j] {
+				return false
+			}
+		}
+	}
+	entries := br.entries()
+	for _, run := range entries {
+		if run < cfg.MinLength || cfg.MaxLength > 0 && run > cfg.MaxLength {
+			return false
+		}
+	}
+	if br.hasBlackSide() {
+		return false
+	}
+	components := br.pieces(false, false)
+	if components == 0 || cfg.Components != AnyComponents && components != cfg.Components {
+		return false
+	}
+	blacks := 0
+	for i := range br.grid {
+		for j := range br.grid[i] {
+			if br.grid[i][j] && !br.outside[i][j] {
+				blacks++
+			}
+		}
+	}
+	if blacks < b.MinBlacks || b.MaxBlacks > 0 && blacks > b.MaxBlacks {
+		return false
+	}
+	if len(entries) < b.MinWords || b.MaxWords > 0 && len(entries) > b.MaxWords {
+		return false
+	}
+	for _, p := range cfg.Forbidden {
+		if br.matches(p) > 0 {
+			return false
+		}
+	}
+	if cfg.Motif != nil {
+		n := br.matches(cfg.Motif)
+		if cfg.MotifCount == 0 && n == 0 || cfg.MotifCount > 0 && n != cfg.MotifCount {
+			return false
+		}
+	}
+	if cfg.BlackRegions > 0 && br.pieces(true, cfg.BlackBorder) != cfg.BlackRegions {
+		return false
+	}
+	if cfg.Biconnected && br.hasCut() {
+		return false
+	}
+	return maxCheaters < 0 || br.cheaters() <= maxCheaters
+}
+
+// shapeOf makes a shape from its rows like ParseShape, with . for the
+// squares outside it
+func shapeOf(rows ...string) [][]bool {
+	shape, err := ParseShape(strings.Join(rows, "\n"))
+	if err != nil {
+		panic(err)
+	}
+	return shape
+}
+
+// bruteCase is a board small enough for the brute force, along with the
+// limits the flags would set
+type bruteCase struct {
+	name        string
+	cfg         Config
+	bounds      Bounds
+	maxCheaters int
+}
+
+var heart = shapeOf(".##.##.", "#######", "#######", ".#####.", "..###..", "...#...")
+var diamond = shapeOf("...#...", "..###..", ".#####.", "#######", ".#####.", "..###..", "...#...")
+
+// bruteCases go through each symmetry, topology and rule at least once,
+// on odd and even sizes, on boards that get stored transposed and on
+// shapes
+var bruteCases = []bruteCase{
+	{"5x5 rot180", Config{Width: 5, Height: 5, Symmetry: Rot180}, Bounds{}, -1},
+	{"5x5 rot180 minlen 1", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1}, Bounds{}, -1},
+	{"6x6 rot180 minlen 2", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2}, Bounds{}, -1},
+	{"7x7 rot180 lr", Config{Width: 7, Height: 7, Symmetry: Rot180 | MirrorLR}, Bounds{}, -1},
+	{"7x7 rot180 lr minlen 2", Config{Width: 7, Height: 7, Symmetry: Rot180 | MirrorLR, MinLength: 2}, Bounds{}, -1},
+	{"7x5 rot180 lr minlen 4", Config{Width: 7, Height: 5, Symmetry: Rot180 | MirrorLR, MinLength: 4}, Bounds{}, -1},
+	{"8x6 rot180 lr minlen 2", Config{Width: 8, Height: 6, Symmetry: Rot180 | MirrorLR, MinLength: 2}, Bounds{}, -1},
+	{"4x4 none minlen 1", Config{Width: 4, Height: 4, MinLength: 1}, Bounds{}, -1},
+	{"5x3 none minlen 2", Config{Width: 5, Height: 3, MinLength: 2}, Bounds{}, -1},
+	{"3x5 none minlen 1", Config{Width: 3, Height: 5, MinLength: 1}, Bounds{}, -1},
+	{"6x5 rot180 minlen 2", Config{Width: 6, Height: 5, Symmetry: Rot180, MinLength: 2}, Bounds{}, -1},
+	{"5x5 lr minlen 1", Config{Width: 5, Height: 5, Symmetry: MirrorLR, MinLength: 1}, Bounds{}, -1},
+	{"6x6 lr minlen 2", Config{Width: 6, Height: 6, Symmetry: MirrorLR, MinLength: 2}, Bounds{}, -1},
+	{"5x5 tb minlen 1", Config{Width: 5, Height: 5, Symmetry: MirrorTB, MinLength: 1}, Bounds{}, -1},
+	{"6x6 tb minlen 2", Config{Width: 6, Height: 6, Symmetry: MirrorTB, MinLength: 2}, Bounds{}, -1},
+	{"5x5 rot90 minlen 2", Config{Width: 5, Height: 5, Symmetry: Rot90 | Rot180 | Rot270, MinLength: 2}, Bounds{}, -1},
+	{"6x6 rot90 minlen 1", Config{Width: 6, Height: 6, Symmetry: Rot90 | Rot180 | Rot270, MinLength: 1}, Bounds{}, -1},
+	{"5x5 diag minlen 2", Config{Width: 5, Height: 5, Symmetry: Diagonal, MinLength: 2}, Bounds{}, -1},
+	{"5x5 antidiag minlen 1", Config{Width: 5, Height: 5, Symmetry: AntiDiagonal, MinLength: 1}, Bounds{}, -1},
+	{"7x7 every symmetry minlen 2", Config{Width: 7, Height: 7, Symmetry: Rot90 | Rot180 | Rot270 | MirrorLR | MirrorTB | Diagonal | AntiDiagonal, MinLength: 2}, Bounds{}, -1},
+	{"4x4 cylinder minlen 2", Config{Width: 4, Height: 4, MinLength: 2, Topology: Cylinder}, Bounds{}, -1},
+	{"5x5 rot180 cylinder minlen 1", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Topology: Cylinder}, Bounds{}, -1},
+	{"4x4 torus minlen 1", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus}, Bounds{}, -1},
+	{"6x4 rot180 torus minlen 1", Config{Width: 6, Height: 4, Symmetry: Rot180, MinLength: 1, Topology: Torus}, Bounds{}, -1},
+	{"5x5 tb torus minlen 2", Config{Width: 5, Height: 5, Symmetry: MirrorTB, MinLength: 2, Topology: Torus}, Bounds{}, -1},
+	{"5x5 rot90 torus minlen 1", Config{Width: 5, Height: 5, Symmetry: Rot90 | Rot180 | Rot270, MinLength: 1, Topology: Torus}, Bounds{}, -1},
+	{"6x6 rot180 maxlen 4", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2, MaxLength: 4}, Bounds{}, -1},
+	{"5x5 lr maxlen 3", Config{Width: 5, Height: 5, Symmetry: MirrorLR, MinLength: 1, MaxLength: 3}, Bounds{}, -1},
+	{"heart lr", Config{Shape: heart, Symmetry: MirrorLR}, Bounds{}, -1},
+	{"heart lr minlen 2", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 2}, Bounds{}, -1},
+	{"heart lr minlen 1", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 1}, Bounds{}, -1},
+	{"diamond rot180 minlen 1", Config{Shape: diamond, Symmetry: Rot180, MinLength: 1}, Bounds{}, -1},
+	{"diamond rot180", Config{Shape: diamond, Symmetry: Rot180}, Bounds{}, -1},
+	{"diamond rot90 minlen 2", Config{Shape: diamond, Symmetry: Rot90 | Rot180 | Rot270, MinLength: 2}, Bounds{}, -1},
+	{"notch none minlen 2", Config{Shape: shapeOf("####", "##..", "####", "####"), MinLength: 2}, Bounds{}, -1},
+	{"6x6 rot180 blacks", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2}, Bounds{MinBlacks: 4, MaxBlacks: 10}, -1},
+	{"6x6 rot180 words", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2}, Bounds{MinWords: 14, MaxWords: 18}, -1},
+	{"5x5 lr blacks and words", Config{Width: 5, Height: 5, Symmetry: MirrorLR, MinLength: 1}, Bounds{MaxBlacks: 6, MinWords: 12}, -1},
+	{"6x6 rot180 partial", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2, Partial: Pattern{"?????#", "??????", "..????", "??????", "??????", "??????"}}, Bounds{}, -1},
+	{"4x4 none partial", Config{Width: 4, Height: 4, MinLength: 1, Partial: Pattern{"?#??", "????", "???.", "????"}}, Bounds{}, -1},
+	{"6x4 lr partial", Config{Width: 6, Height: 4, Symmetry: MirrorLR, MinLength: 2, Partial: Pattern{"??????", "#?????", "??????", "???.??"}}, Bounds{}, -1},
+	{"heart partial", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 1, Partial: Pattern{"???????", "??#????", "???????", "???????", "???.???", "???????"}}, Bounds{}, -1},
+	{"4x4 torus partial", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, Partial: Pattern{"#???", "????", "????", "???."}}, Bounds{}, -1},
+	{"5x5 rot180 no 2x2 blacks", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Forbidden: []Pattern{{"##", "##"}}}, Bounds{}, -1},
+	{"5x5 rot180 no cheater shapes", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Forbidden: []Pattern{{"#.", ".#"}, {".#", "#."}}}, Bounds{}, -1},
+	{"4x4 torus no ##.", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, Forbidden: []Pattern{{"##."}}}, Bounds{}, -1},
+	{"4x4 torus no staircases", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, Forbidden: []Pattern{{"#?", "?#"}}}, Bounds{}, -1},
+	{"heart no white 2x2", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 2, Forbidden: []Pattern{{"..", ".."}}}, Bounds{}, -1},
+	{"5x5 rot180 motif", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Motif: Pattern{"#.", ".#"}}, Bounds{}, -1},
+	{"5x5 rot180 motif twice", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Motif: Pattern{"#.", ".#"}, MotifCount: 2}, Bounds{}, -1},
+	{"4x4 torus motif", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, Motif: Pattern{"#", "."}, MotifCount: 3}, Bounds{}, -1},
+	{"heart motif", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 1, Motif: Pattern{".#."}}, Bounds{}, -1},
+	{"5x5 rot90 motif", Config{Width: 5, Height: 5, Symmetry: Rot90 | Rot180 | Rot270, MinLength: 2, Motif: Pattern{"##"}, MotifCount: 4}, Bounds{}, -1},
+	{"5x5 rot180 2 components", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Components: 2}, Bounds{}, -1},
+	{"6x6 rot180 any components", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2, Components: AnyComponents}, Bounds{}, -1},
+	{"5x5 lr 3 components", Config{Width: 5, Height: 5, Symmetry: MirrorLR, MinLength: 1, Components: 3}, Bounds{}, -1},
+	{"4x4 none 2 components", Config{Width: 4, Height: 4, MinLength: 1, Components: 2}, Bounds{}, -1},
+	{"4x4 torus any components", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, Components: AnyComponents}, Bounds{}, -1},
+	{"heart 2 components", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 1, Components: 2}, Bounds{}, -1},
+	{"5x5 rot180 2 black regions", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, BlackRegions: 2}, Bounds{}, -1},
+	{"6x6 rot180 black regions touch the border", Config{Width: 6, Height: 6, Symmetry: Rot180, MinLength: 2, BlackRegions: 1, BlackBorder: true}, Bounds{}, -1},
+	{"heart black regions touch the border", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 2, BlackRegions: 1, BlackBorder: true}, Bounds{}, -1},
+	{"4x4 torus 2 black regions", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, BlackRegions: 2}, Bounds{}, -1},
+	{"5x5 lr cylinder black regions touch the border", Config{Width: 5, Height: 5, Symmetry: MirrorLR, MinLength: 1, Topology: Cylinder, BlackRegions: 1, BlackBorder: true}, Bounds{}, -1},
+	{"5x5 rot180 biconnected", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1, Biconnected: true}, Bounds{}, -1},
+	{"6x6 lr biconnected any components", Config{Width: 6, Height: 6, Symmetry: MirrorLR, MinLength: 2, Components: AnyComponents, Biconnected: true}, Bounds{}, -1},
+	{"4x4 torus biconnected", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus, Biconnected: true}, Bounds{}, -1},
+	{"heart biconnected", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 1, Biconnected: true}, Bounds{}, -1},
+	{"5x5 rot180 no cheaters", Config{Width: 5, Height: 5, Symmetry: Rot180, MinLength: 1}, Bounds{}, 0},
+	{"6x6 lr one cheater", Config{Width: 6, Height: 6, Symmetry: MirrorLR, MinLength: 2}, Bounds{}, 1},
+	{"5x5 lr cylinder no cheaters", Config{Width: 5, Height: 5, Symmetry: MirrorLR, MinLength: 2, Topology: Cylinder}, Bounds{}, 0},
+	{"4x4 torus no cheaters", Config{Width: 4, Height: 4, MinLength: 1, Topology: Torus}, Bounds{}, 0},
+	{"6x4 rot180 torus one cheater", Config{Width: 6, Height: 4, Symmetry: Rot180, MinLength: 1, Topology: Torus}, Bounds{}, 1},
+	{"5x5 rot90 torus one cheater", Config{Width: 5, Height: 5, Symmetry: Rot90 | Rot180 | Rot270, MinLength: 1, Topology: Torus}, Bounds{}, 1},
+}
@@ -2,222 +2,515 @@ package main
 
 import (
 	. "./cross"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"math/big"
+	"math/rand"
 	"os"
 	"runtime/pprof"
-	"sync"
+	"strings"
 )
 
-const Profile bool = true
-const NumThreads int = 15
-const LogFrequency uint64 = 10000000
-
-// These help with debugging / special cases
-// but they increase the number of states / time / memory
-const IncludeBoardString = false
-const IncludeBoardArr = false
-const IncludeHasEdge = true
+// report is where everything goes but the -marginals table, which has
+// stdout to itself, so everything else goes to stderr with it
+var report io.Writer = os.Stdout
 
 func main() {
+	from := flag.Int("from", 21, "smallest board size to count")
+	to := flag.Int("to", 0, "largest board size to count, defaults to -from")
+	step := flag.Int("step", 2, "gap between board sizes in the sweep, 1 includes the even sizes")
+	width := flag.Int("width", 0, "board width, to count one rectangle instead of sweeping squares")
+	height := flag.Int("height", 0, "board height, defaults to -width")
+	minLength := flag.Int("minlen", 3, "shortest entry allowed")
+	maxLength := flag.Int("maxlen", 0, "longest entry allowed, 0 for no limit")
+	unlabelled := flag.Bool("unlabelled", false, "also count boards up to rotation and reflection")
+	topologyFlag := flag.String("topology", "flat", "which edges wrap around, flat, cylinder for the left and right edges or torus for all of them")
+	shapeFile := flag.String("shape", "", "file with a board shape to count instead of a size, a line per row with . for squares outside it")
+	symmetryFlag := flag.String("sym", "rot180,lr", "symmetries every board has, a comma separated list of rot90, rot180, lr, tb, diag and antidiag, or none")
+	printBlacks := flag.Bool("blacks", false, "also print how many boards there are with each number of black squares")
+	printWords := flag.Bool("words", false, "also print a CSV table of how many boards there are with each number of words and black squares")
+	lengths := flag.Bool("lengths", false, "also print how many entries there are of each length, and how many boards have one at least that long")
+	var bounds Bounds
+	flag.IntVar(&bounds.MinBlacks, "minblacks", 0, "fewest black squares allowed")
+	flag.IntVar(&bounds.MaxBlacks, "maxblacks", 0, "most black squares allowed, 0 for no limit")
+	flag.IntVar(&bounds.MinWords, "minwords", 0, "fewest words allowed")
+	flag.IntVar(&bounds.MaxWords, "maxwords", 0, "most words allowed, 0 for no limit")
+	maxCheaters := flag.Int("maxcheaters", -1, "most cheaters allowed, black squares that don't change the word count, -1 for no limit")
+	components := flag.Int("components", 1, "how many separate pieces the white squares have to be in, -1 for any number")
+	blackRegions := flag.Int("blackregions", 0, "how many separate pieces the black squares have to be in, 0 for any number")
+	blackBorder := flag.Bool("border", false, "count the edge of the board as one piece of black squares along with everything touching it, so -blackregions 1 means every black square connects to the edge")
+	biconnected := flag.Bool("biconnected", false, "only count boards where no one white square splits the white squares it's with into more pieces")
+	forbidFile := flag.String("forbid", "", "file with patterns that can't show up anywhere on a board, separated by blank lines, with # for black, . for white and ? for either")
+	motifFile := flag.String("motif", "", "file with a pattern that has to show up on every board, like the ones for -forbid")
+	motifCount := flag.Int("motifcount", 0, "how many times the motif has to show up, 0 for at least once")
+	marginals := flag.String("marginals", "", "also print the exact fraction of boards that have each square black, as csv or json, which then gets stdout to itself")
+	samples := flag.Int("sample", 0, "also print this many boards picked uniformly at random from the ones counted")
+	seed := flag.Int64("seed", 1, "random seed for -sample, the same seed picks the same boards")
+	rankFile := flag.String("rank", "", "file with a board, like a -partial board with every square filled in, to also print where it is among the boards counted, in the order they'd be in as strings, top row first with # before .")
+	unrank := flag.String("unrank", "", "also print the board that's this far along in the order -rank uses, from 0")
+	forced := flag.Bool("forced", false, "also print which squares are black in every board and which are white in every one, like a -partial board")
+	partialFile := flag.String("partial", "", "file with a board that has some squares filled in already, like a -forbid pattern the size of the board with ? for the empty squares, to count the boards that fill in the rest")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile to this file")
+	flag.Parse()
+	if *step < 1 {
+		fmt.Printf("-step has to be at least 1, got %v\n", *step)
+		os.Exit(1)
+	}
+	if *marginals != "" && *marginals != "csv" && *marginals != "json" {
+		fmt.Printf("-marginals has to be csv or json, got %q\n", *marginals)
+		os.Exit(1)
+	}
+	if *marginals != "" {
+		report = os.Stderr
+	}
+	symmetry, err := ParseSymmetry(*symmetryFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	topology, err := ParseTopology(*topologyFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *to < *from {
+		*to = *from
+	}
+	if *height == 0 {
+		*height = *width
+	}
+	base := Config{MinLength: *minLength, MaxLength: *maxLength, Symmetry: symmetry, Topology: topology, Components: *components, BlackRegions: *blackRegions, BlackBorder: *blackBorder, Biconnected: *biconnected, MotifCount: *motifCount, EntryLengths: *lengths, Bounds: bounds, CountBlacks: *printBlacks || *printWords, CountWords: *printWords, MaxCheaters: cheaterLimit(*maxCheaters), ReadingOrder: *rankFile != "" || *unrank != ""}
+	if *forbidFile != "" {
+		text, err := os.ReadFile(*forbidFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		base.Forbidden, err = ParsePatterns(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *motifFile != "" {
+		text, err := os.ReadFile(*motifFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		base.Motif, err = ParseMotif(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if *partialFile != "" {
+		text, err := os.ReadFile(*partialFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		base.Partial, err = ParsePartial(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	var configs []Config
+	if *shapeFile != "" {
+		text, err := os.ReadFile(*shapeFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		cfg := base
+		cfg.Shape, err = ParseShape(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		cfg.Width, cfg.Height = len(cfg.Shape[0]), len(cfg.Shape)
+		configs = append(configs, cfg)
+	} else if *width > 0 {
+		cfg := base
+		cfg.Width, cfg.Height = *width, *height
+		configs = append(configs, cfg)
+	} else if base.Partial != nil {
+		// the partial board is the size of the board
+		cfg := base
+		cfg.Width, cfg.Height = len(cfg.Partial[0]), len(cfg.Partial)
+		configs = append(configs, cfg)
+	} else {
+		for size := *from; size <= *to; size += *step {
+			cfg := base
+			cfg.Width, cfg.Height = size, size
+			configs = append(configs, cfg)
+		}
+	}
 
-	if Profile {
-		f, _ := os.Create("profile.prof")
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 		defer f.Close()
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
 
-	// initialize a bunch of useful info for
-	// this BoardSize
-	fmt.Printf("Initializing stuff for board size %v...\n", BoardSize)
-
-	type State struct {
-		lastRow     uint32
-		topReach    [ReachSize]uint8
-		bottomReach [ReachSize]uint8
-		dist        [DistSize]uint8
-		boardString string
-		hasEdge     bool
-		isMirror    bool
-	}
-
-	var mutex sync.Mutex
-	dp := make(map[State]*big.Int)
-
-	// Initialize the DP with the middle row and the row adjacent to it
-	for _, middleRow := range PossMiddleRows {
-		possFromMiddleRowList := PossFromMiddleMap[middleRow].ToNums()
-		middleReach := RowToReachMap[middleRow]
-		for _, possFromMiddleRow := range possFromMiddleRowList {
-			if possFromMiddleRow > RevMap[possFromMiddleRow] {
-				continue
+	for _, cfg := range configs {
+		// initialize a bunch of useful info for
+		// this board size
+		fmt.Fprintf(os.Stderr, "Initializing stuff for board size %vx%v with symmetry %v...\n", cfg.Width, cfg.Height, cfg.Symmetry)
+		result, err := Count(cfg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		poly := result.Boards
+		total := result.Total()
+		if *unlabelled {
+			n, err := countUnlabelled(cfg, total)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
 			}
-
-			// the first 3 rows are RevMap[possFromMiddleRow], middleRow, possFromMiddleRow
-			initialDist := InitDist(RevMap[possFromMiddleRow], middleRow, possFromMiddleRow)
-			ok, initialTopReach, initialBottomReach :=
-				ApplyReach(
-					middleReach,
-					middleReach,
-					middleRow,
-					possFromMiddleRow,
-				)
-
-			if !ok {
-				continue
+			fmt.Fprintf(report, "DONE! %vx%v total %v unlabelled %v\n", cfg.Width, cfg.Height, total, n)
+		} else {
+			fmt.Fprintf(report, "DONE! %vx%v total %v\n", cfg.Width, cfg.Height, total)
+		}
+		if *printWords {
+			fmt.Fprintln(report, "words,blacks,boards")
+			for words, blacks := range poly {
+				for k, c := range blacks {
+					if c.Sign() != 0 {
+						fmt.Fprintf(report, "%v,%v,%v\n", words, k, c)
+					}
+				}
 			}
-
-			boardString := ""
-			if IncludeBoardString {
-				boardString =
-					fmt.Sprintf(
-						"%v%v%v",
-						RowToString(possFromMiddleRow),
-						RowToString(middleRow),
-						RowToString(RevMap[possFromMiddleRow]),
-					)
+		} else if *printBlacks {
+			for k, c := range poly.SumY() {
+				if c.Sign() != 0 {
+					fmt.Fprintf(report, "%v boards with %v black squares\n", c, k)
+				}
 			}
-
-			state := State{
-				possFromMiddleRow,
-				initialTopReach,
-				initialBottomReach,
-				initialDist,
-				boardString,
-				!IncludeHasEdge || RowHasEdge(middleRow) || RowHasEdge(possFromMiddleRow),
-				MiddleRowMap[possFromMiddleRow],
+		}
+		// the rest go back through every layer of the DP, which it runs
+		// again for the first one that needs it, since -unlabelled moves
+		// on to other symmetries
+		var lay *Layers
+		layered := func() *Layers {
+			if lay == nil {
+				lay, err = CountLayers(cfg)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
 			}
-			_, exists := dp[state]
-			if !exists {
-				dp[state] = big.NewInt(0)
+			return lay
+		}
+		if *lengths && total.Sign() != 0 {
+			printLengths(result.Lengths, total)
+		}
+		if *marginals != "" {
+			printMarginals(cfg, layered(), *marginals)
+		}
+		if *forced {
+			printForced(layered())
+		}
+		if *samples > 0 {
+			printSamples(layered(), *samples, rand.New(rand.NewSource(*seed)))
+		}
+		if *rankFile != "" {
+			text, err := os.ReadFile(*rankFile)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			p, err := ParseBoard(string(text))
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
 			}
-			dp[state].Add(dp[state], big.NewInt(1))
+			rank, err := RankBoard(layered(), p)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if rank == nil {
+				fmt.Fprintln(report, "that isn't one of the boards")
+				os.Exit(1)
+			}
+			fmt.Fprintf(report, "rank %v\n", rank)
+		}
+		if *unrank != "" {
+			rank, ok := new(big.Int).SetString(*unrank, 10)
+			if !ok {
+				fmt.Printf("-unrank needs a number, got %q\n", *unrank)
+				os.Exit(1)
+			}
+			board, err := UnrankBoard(layered(), rank)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			// -rank skips everything up to the header
+			fmt.Fprintf(report, "board %v:\n", rank)
+			printBoard(board)
 		}
 	}
+}
 
-	isConnected := func(state State) bool {
-		return GetMaxComponentInReach(state.topReach) < 2 &&
-			GetMaxComponentInReach(state.bottomReach) < 2
+// cheaterLimit turns a -maxcheaters flag, which is -1 for no limit, into
+// the Config.MaxCheaters for it
+func cheaterLimit(most int) int {
+	switch {
+	case most < 0:
+		return 0
+	case most == 0:
+		return NoCheaters
 	}
+	return most
+}
 
-	getPossibleNextRows := func(state State, index int) []uint32 {
-		// After adding one all-black row, we can only add more
-		// all-black rows
-		if state.lastRow == MaxRow {
-			return []uint32{MaxRow}
-		} else {
-			possRows := GetPossibleNextRowsForDist(state.dist)
-			if index > HalfBoardSize-3 {
-				possRows = possRows.And(AvoidOneZeroBitArrayMap[state.lastRow])
-			}
-			ret := possRows.ToNums()
+// printBoard prints a board with every square filled in, leaving the
+// squares outside the shape blank
+func printBoard(board Pattern) {
+	for _, line := range board {
+		fmt.Fprintln(report, line)
+	}
+}
 
-			// If we're at a valid stopping state, we can start
-			// adding all-black rows
-			if isConnected(state) && IsValidDist(state.dist) {
-				ret = append(ret, MaxRow)
-			}
-			return ret
+// printSamples prints n boards picked uniformly at random from the ones
+// lay has
+func printSamples(lay *Layers, n int, rng *rand.Rand) {
+	for k := 0; k < n; k++ {
+		board := SampleBoard(lay, rng)
+		if board == nil {
+			fmt.Fprintln(report, "no boards to pick from")
+			return
 		}
+		fmt.Fprintf(report, "sample %v:\n", k+1)
+		printBoard(board)
 	}
+}
 
-	fmt.Println("Starting DP...")
-	// Do the actual DP, parallelize some stuff too
-	for curIndex := 2; curIndex < HalfBoardSize; curIndex++ {
-		newDp := make(map[State]*big.Int)
-		var keyList []State
-		for key, _ := range dp {
-			keyList = append(keyList, key)
+// printMarginals prints the fraction of the boards cfg describes that
+// have each square black, exactly, as a CSV table with a line per row or
+// as JSON. Squares outside the shape are empty in the CSV and null in the
+// JSON. lay has the DP's layers for cfg.
+func printMarginals(cfg Config, lay *Layers, format string) {
+	total := lay.Total()
+	if total.Sign() == 0 {
+		fmt.Fprintln(report, "no boards, so no fractions")
+		return
+	}
+	counts := lay.BlackCounts()
+	fractions := make([][]*string, len(counts))
+	for i, row := range counts {
+		fractions[i] = make([]*string, len(row))
+		for j, n := range row {
+			if n != nil {
+				fraction := new(big.Rat).SetFrac(n, total).RatString()
+				fractions[i][j] = &fraction
+			}
 		}
+	}
+	if format == "json" {
+		out, err := json.Marshal(struct {
+			Width     int         `json:"width"`
+			Height    int         `json:"height"`
+			Total     string      `json:"total"`
+			Fractions [][]*string `json:"fractions"`
+		}{cfg.Width, cfg.Height, total.String(), fractions})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println(string(out))
+		return
+	}
+	for _, row := range fractions {
+		for j, fraction := range row {
+			if j > 0 {
+				fmt.Print(",")
+			}
+			if fraction != nil {
+				fmt.Print(*fraction)
+			}
+		}
+		fmt.Println()
+	}
+}
 
-		run := func(wg *sync.WaitGroup, thread_id int) {
-			defer wg.Done()
-			for ind, state := range keyList {
-				if ind%NumThreads != thread_id {
-					continue
-				}
-
-				// iterate over all possible next rows
-				for _, nextRow := range getPossibleNextRows(state, curIndex) {
-					// Once we're done with the mirror-symmetric part, we only need to
-					// store one copy of each mirror-symmetric board. So only take
-					// one of every row, RevMap[row] pair
-					if state.isMirror && nextRow > RevMap[nextRow] {
-						continue
-					}
-
-					ok, nextTopReach, nextBottomReach :=
-						ApplyReach(
-							state.topReach,
-							state.bottomReach,
-							state.lastRow,
-							nextRow,
-						)
-					if !ok {
-						continue
-					}
-
-					nextDist := ApplyDist(state.dist, nextRow)
-					boardString := ""
-					if IncludeBoardString {
-						boardString =
-							fmt.Sprintf(
-								"%v   %v\n%v\n%v   %v",
-								ReachToString(nextTopReach),
-								RowToString(nextRow),
-								state.boardString,
-								ReachToString(nextBottomReach),
-								RowToString(RevMap[nextRow]),
-							)
-					}
+// printForced prints the squares that are the same in every one of the
+// boards cfg describes, with # for the ones that are always black, . for
+// always white and ? for the rest. Squares outside the shape are blank.
+// lay has the DP's layers for cfg.
+func printForced(lay *Layers) {
+	if lay.Total().Sign() == 0 {
+		fmt.Fprintln(report, "no boards, so nothing is forced")
+		return
+	}
+	fmt.Fprintln(report, "forced squares:")
+	for _, line := range forcedSquares(lay) {
+		fmt.Fprintln(report, line)
+	}
+}
 
-					nextState := State{
-						nextRow,
-						nextTopReach,
-						nextBottomReach,
-						nextDist,
-						boardString,
-						!IncludeHasEdge || state.hasEdge || RowHasEdge(nextRow),
-						state.isMirror && MiddleRowMap[nextRow],
-					}
-					mutex.Lock()
-					_, exists := newDp[nextState]
-					if !exists {
-						newDp[nextState] = big.NewInt(0)
-					}
-					newDp[nextState].Add(newDp[nextState], dp[state])
-					mutex.Unlock()
-				}
+// forcedSquares gets what printForced prints from how many boards have
+// each square black: none means it's always white, and all of them means
+// it's always black
+func forcedSquares(lay *Layers) Pattern {
+	total := lay.Total()
+	counts := lay.BlackCounts()
+	ret := make(Pattern, len(counts))
+	for i, row := range counts {
+		line := make([]byte, len(row))
+		for j, n := range row {
+			switch {
+			case n == nil:
+				line[j] = ' '
+			case n.Sign() == 0:
+				line[j] = '.'
+			case n.Cmp(total) == 0:
+				line[j] = '#'
+			default:
+				line[j] = '?'
 			}
 		}
-		var wg sync.WaitGroup
-		wg.Add(NumThreads)
-		for thread_id := 0; thread_id < NumThreads; thread_id++ {
-			go run(&wg, thread_id)
-		}
-		wg.Wait()
-		dp = newDp
-		fmt.Printf("Done with round %v, DP has %v states.\n", curIndex, len(dp))
+		ret[i] = string(line)
 	}
+	return ret
+}
 
-	ans := big.NewInt(0)
-	for state, count := range dp {
-		if !isConnected(state) {
+// printLengths prints the entry length stats for total boards
+func printLengths(lengths LengthStats, total *big.Int) {
+	// atLeast[k] is how many boards have an entry at least k long
+	atLeast := make([]*big.Int, len(lengths.Longest)+1)
+	atLeast[len(lengths.Longest)] = new(big.Int)
+	for k := len(lengths.Longest) - 1; k >= 0; k-- {
+		atLeast[k] = new(big.Int).Add(atLeast[k+1], lengths.Longest[k])
+	}
+	for k := 1; k < len(lengths.Entries); k++ {
+		entries := lengths.Entries[k]
+		if entries.Sign() == 0 {
 			continue
 		}
-		if !state.hasEdge || state.lastRow == MaxRow {
+		perBoard := new(big.Rat).SetFrac(entries, total)
+		fmt.Fprintf(report, "%v entries of length %v, %v per board (about %v), %v boards have one at least that long\n",
+			entries, k, perBoard.RatString(), perBoard.FloatString(4), atLeast[k])
+	}
+}
+
+// countUnlabelled counts boards up to rotation and reflection using
+// Burnside's lemma, which says that's the average number of boards each
+// rotation or reflection leaves alone. Those are just the boards that
+// have it as an extra symmetry, so we can count them the usual way.
+// total is what Count already got for cfg, and cross has to still be set
+// up for it, for KeepsShape.
+func countUnlabelled(cfg Config, total *big.Int) (*big.Int, error) {
+	if total.Sign() == 0 {
+		return new(big.Int), nil
+	}
+	// only the ones that send the boards to each other count. With a
+	// shape, the others change where it is, and on a cylinder they change
+	// which way it wraps around
+	var candidates []Symmetry
+	for _, g := range cfg.Symmetry.Equivalences(cfg.Width, cfg.Height) {
+		if KeepsShape(g) && cfg.Topology.Keeps(g) {
+			candidates = append(candidates, g)
+		}
+	}
+	var equivalences []Symmetry
+	for _, g := range candidates {
+		keeps, err := keepsBoards(cfg, g, total)
+		if err != nil {
+			return nil, err
+		}
+		if keeps {
+			equivalences = append(equivalences, g)
+		}
+	}
+	sum := big.NewInt(0)
+	for _, g := range equivalences {
+		fixedCfg := cfg
+		fixedCfg.Symmetry |= g
+		if fixedCfg.Symmetry.Closure() == cfg.Symmetry.Closure() {
+			// every board already has this symmetry
+			sum.Add(sum, total)
 			continue
 		}
-		if state.isMirror {
-			ans.Add(ans, count)
-		} else {
-			ans.Add(ans, count)
-			ans.Add(ans, count)
+		counts, err := Count(fixedCfg)
+		if err != nil {
+			return nil, err
 		}
-		if IncludeBoardString {
-			fmt.Printf("board %v\n\n", state.boardString)
+		fixed := counts.Total()
+		fmt.Fprintf(report, "%v of them also have symmetry %v\n", fixed, g)
+		sum.Add(sum, fixed)
+	}
+	return sum.Div(sum, big.NewInt(int64(len(equivalences)))), nil
+}
+
+// keepsBoards says whether g sends the total boards cfg describes to
+// themselves. It's easy when it keeps the forbidden patterns, the motif
+// and the squares that are filled in already, but it can still keep the
+// boards when it changes them, like flipping #. to .# going round a
+// torus, which shows up just as often. g sends the boards to the ones
+// that follow the rules it changes them to, so it keeps them if every
+// board follows both sets of rules, which is another count. A motif that
+// changes can't go in that count though, so if the rest doesn't rule g
+// out, it's an error.
+func keepsBoards(cfg Config, g Symmetry, total *big.Int) (bool, error) {
+	same := func(a, b Pattern) bool {
+		return strings.Join(a, "\n") == strings.Join(b, "\n")
+	}
+	keepsMotif := cfg.Motif == nil || same(cfg.Motif.Transform(g), cfg.Motif)
+	keeps := keepsMotif && (cfg.Partial == nil || same(cfg.Partial.Transform(g), cfg.Partial))
+	both := cfg
+	both.Forbidden = nil
+	for _, p := range cfg.Forbidden {
+		turned := p.Transform(g)
+		found := false
+		for _, q := range cfg.Forbidden {
+			found = found || same(turned, q)
 		}
+		keeps = keeps && found
+		both.Forbidden = append(both.Forbidden, p, turned)
+	}
+	if keeps {
+		return true, nil
+	}
+	if cfg.Partial != nil {
+		both.Partial = make(Pattern, len(cfg.Partial))
+		for i, line := range cfg.Partial.Transform(g) {
+			merged := []byte(cfg.Partial[i])
+			for j := range merged {
+				switch {
+				case merged[j] == '?':
+					merged[j] = line[j]
+				case line[j] != '?' && line[j] != merged[j]:
+					// no board has both
+					return false, nil
+				}
+			}
+			both.Partial[i] = string(merged)
+		}
+	}
+	counts, err := Count(both)
+	if err != nil {
+		// cfg works, so that's only the symmetry filling in a square
+		// of the two partial boards differently, which no board has
+		return false, nil
+	}
+	if counts.Total().Cmp(total) != 0 {
+		return false, nil
+	}
+	if !keepsMotif {
+		return false, fmt.Errorf("can't count boards up to rotation and reflection when %v changes the motif", g)
 	}
-	fmt.Printf("DONE! total %v\n", ans)
+	return true, nil
 }
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "./cross"
+)
+
+// withLimits is cfg in b and with at most most cheaters, or any number if
+// it's -1, the way main sets it up
+func withLimits(cfg Config, b Bounds, most int) Config {
+	cfg.Bounds, cfg.MaxCheaters = b, cheaterLimit(most)
+	return cfg
+}
+
+// countFor counts the boards for cfg, in b and with at most most
+// cheaters
+func countFor(t *testing.T, cfg Config, b Bounds, most int) int64 {
+	t.Helper()
+	counts, err := Count(withLimits(cfg, b, most))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counts.Total().Int64()
+}
+
+func TestCountMatchesBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			want := int64(len(bruteBoards(c.cfg, c.bounds, c.maxCheaters)))
+			if got := countFor(t, c.cfg, c.bounds, c.maxCheaters); got != want {
+				t.Errorf("got %v boards, brute force found %v", got, want)
+			}
+		})
+	}
+}
+
+// Shapes with a tip have squares outside the shape all along a side, which
+// mustn't get taken for a black side and leave no boards at all
+func TestShapeWithTips(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		cfg    Config
+		boards int64
+	}{
+		{"heart lr", Config{Shape: heart, Symmetry: MirrorLR, MinLength: 3}, 5},
+		{"diamond rot180", Config{Shape: diamond, Symmetry: Rot180, MinLength: 3}, 4},
+		{"diamond lr", Config{Shape: diamond, Symmetry: MirrorLR, MinLength: 3}, 10},
+	} {
+		if got := countFor(t, c.cfg, Bounds{}, -1); got != c.boards {
+			t.Errorf("%v: got %v boards, want %v", c.name, got, c.boards)
+		}
+	}
+}
+
+// turns has every way to rotate or reflect a board given as its rows,
+// the quarter turns and diagonals only when it's square
+func turns(rows []string) [][]string {
+	h, w := len(rows), len(rows[0])
+	maps := []func(i, j int) (int, int){
+		func(i, j int) (int, int) { return i, j },
+		func(i, j int) (int, int) { return h - 1 - i, w - 1 - j },
+		func(i, j int) (int, int) { return i, w - 1 - j },
+		func(i, j int) (int, int) { return h - 1 - i, j },
+	}
+	if w == h {
+		maps = append(maps,
+			func(i, j int) (int, int) { return j, i },
+			func(i, j int) (int, int) { return w - 1 - j, h - 1 - i },
+			func(i, j int) (int, int) { return j, h - 1 - i },
+			func(i, j int) (int, int) { return w - 1 - j, i },
+		)
+	}
+	var ret [][]string
+	for _, m := range maps {
+		turned := make([][]byte, h)
+		for i := range turned {
+			turned[i] = make([]byte, w)
+		}
+		for i := range rows {
+			for j := range rows[i] {
+				a, b := m(i, j)
+				turned[a][b] = rows[i][j]
+			}
+		}
+		lines := make([]string, h)
+		for i := range turned {
+			lines[i] = string(turned[i])
+		}
+		ret = append(ret, lines)
+	}
+	return ret
+}
+
+// The brute force doesn't know which rotations and reflections count, so
+// it uses the ones that take the set of boards to itself, and counts the
+// boards each one can get to from the board that comes first
+func TestUnlabelledMatchesBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			boards := bruteBoards(c.cfg, c.bounds, c.maxCheaters)
+			if len(boards) == 0 {
+				return
+			}
+			set := make(map[string]bool)
+			for _, board := range boards {
+				set[board] = true
+			}
+			keeps := make([]bool, len(turns(strings.Split(boards[0], "\n"))))
+			for k := range keeps {
+				keeps[k] = true
+				for _, board := range boards {
+					if !set[strings.Join(turns(strings.Split(board, "\n"))[k], "\n")] {
+						keeps[k] = false
+						break
+					}
+				}
+			}
+			var want int64
+			for _, board := range boards {
+				first := true
+				for k, turned := range turns(strings.Split(board, "\n")) {
+					if keeps[k] && strings.Join(turned, "\n") < board {
+						first = false
+					}
+				}
+				if first {
+					want++
+				}
+			}
+			total := big.NewInt(countFor(t, c.cfg, c.bounds, c.maxCheaters))
+			got, err := countUnlabelled(withLimits(c.cfg, c.bounds, c.maxCheaters), total)
+			if err != nil {
+				// it can only refuse when a motif changes
+				if c.cfg.Motif == nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if got.Int64() != want {
+				t.Errorf("got %v boards up to rotation and reflection, brute force found %v", got, want)
+			}
+		})
+	}
+}
+
+// The -words table is counted by words and black squares together, and
+// the bounds prune it as it goes, so check every entry of it and not just
+// the total
+func TestWordsMatchBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			want := make(map[[2]int]int64)
+			for _, board := range bruteBoards(c.cfg, c.bounds, c.maxCheaters) {
+				want[[2]int{len(entryLengths(c.cfg, board)), strings.Count(board, "#")}]++
+			}
+			cfg := withLimits(c.cfg, c.bounds, c.maxCheaters)
+			cfg.CountBlacks, cfg.CountWords = true, true
+			counts, err := Count(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := counts.Boards
+			for words, blacks := range got {
+				for k, n := range blacks {
+					key := [2]int{words, k}
+					if n.Int64() != want[key] {
+						t.Errorf("got %v boards with %v words and %v black squares, brute force found %v", n, words, k, want[key])
+					}
+					delete(want, key)
+				}
+			}
+			for key, n := range want {
+				if n > 0 {
+					t.Errorf("got no boards with %v words and %v black squares, brute force found %v", key[0], key[1], n)
+				}
+			}
+		})
+	}
+}
+
+func TestLengthsMatchBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			// entries[k] is how many entries are k long, and longest[k]
+			// how many boards have their longest entry k long
+			if c.bounds != (Bounds{}) {
+				// Init doesn't allow entry lengths with bounds
+				return
+			}
+			var entries, longest [32]int64
+			for _, board := range bruteBoards(c.cfg, c.bounds, c.maxCheaters) {
+				most := 0
+				for _, length := range entryLengths(c.cfg, board) {
+					entries[length]++
+					most = max(most, length)
+				}
+				longest[most]++
+			}
+			cfg := withLimits(c.cfg, c.bounds, c.maxCheaters)
+			cfg.EntryLengths = true
+			counts, err := Count(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := counts.Lengths
+			for k := range entries {
+				var n, m int64
+				if k < len(got.Entries) {
+					n = got.Entries[k].Int64()
+				}
+				if k < len(got.Longest) {
+					m = got.Longest[k].Int64()
+				}
+				if n != entries[k] {
+					t.Errorf("got %v entries of length %v, brute force found %v", n, k, entries[k])
+				}
+				if m != longest[k] {
+					t.Errorf("got %v boards with a longest entry of %v, brute force found %v", m, k, longest[k])
+				}
+			}
+		})
+	}
+}
+
+// layersFor is countFor for CountLayers
+func layersFor(t *testing.T, c bruteCase) *Layers {
+	t.Helper()
+	lay, err := CountLayers(withLimits(c.cfg, c.bounds, c.maxCheaters))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lay
+}
+
+func TestMarginalsMatchBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			boards := bruteBoards(c.cfg, c.bounds, c.maxCheaters)
+			lay := layersFor(t, c)
+			if got := lay.Total(); got.Int64() != int64(len(boards)) {
+				t.Fatalf("got %v boards, brute force found %v", got, len(boards))
+			}
+			for i, row := range lay.BlackCounts() {
+				for j, n := range row {
+					blacks := 0
+					for _, board := range boards {
+						if strings.Split(board, "\n")[i][j] == '#' {
+							blacks++
+						}
+					}
+					switch {
+					case c.cfg.Shape != nil && c.cfg.Shape[i][j]:
+						if n != nil {
+							t.Errorf("square %v,%v is outside the shape but got a count", i, j)
+						}
+					case n == nil || n.Int64() != int64(blacks):
+						t.Errorf("square %v,%v: got %v boards with it black, brute force found %v", i, j, n, blacks)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestForcedMatchesBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			boards := bruteBoards(c.cfg, c.bounds, c.maxCheaters)
+			if len(boards) == 0 {
+				return
+			}
+			for i, line := range forcedSquares(layersFor(t, c)) {
+				for j := range line {
+					want := strings.Split(boards[0], "\n")[i][j]
+					for _, board := range boards {
+						if strings.Split(board, "\n")[i][j] != want {
+							want = '?'
+						}
+					}
+					if line[j] != want {
+						t.Errorf("square %v,%v: got %q, brute force found %q", i, j, line[j], want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Each board should come up about as often as every other one. With 50
+// tries per board, the chi-squared statistic has a mean of one less than
+// the number of boards, and it's hardly ever more than a few standard
+// deviations past that unless some boards come up more than others
+func TestSamplesMatchBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			seen := make(map[string]int)
+			for _, board := range bruteBoards(c.cfg, c.bounds, c.maxCheaters) {
+				seen[board] = 0
+			}
+			if len(seen) == 0 || len(seen) > 200 {
+				return
+			}
+			lay := layersFor(t, c)
+			const tries = 50
+			for k := 0; k < tries*len(seen); k++ {
+				board := strings.Join(SampleBoard(lay, rng), "\n")
+				if _, ok := seen[board]; !ok {
+					t.Fatalf("got a board the brute force didn't find:\n%v", board)
+				}
+				seen[board]++
+			}
+			chiSquared := 0.0
+			for board, n := range seen {
+				if n == 0 {
+					t.Errorf("never got this board:\n%v", board)
+				}
+				chiSquared += float64((n-tries)*(n-tries)) / tries
+			}
+			df := float64(len(seen) - 1)
+			if limit := df + 6*math.Sqrt(2*df); chiSquared > limit {
+				t.Errorf("chi-squared is %.1f with %v boards, over %.1f, so some come up more often than others", chiSquared, len(seen), limit)
+			}
+		})
+	}
+}
+
+func TestRanksMatchBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			boards := bruteBoards(c.cfg, c.bounds, c.maxCheaters)
+			if len(boards) > 200 {
+				return
+			}
+			seen := make(map[string]bool)
+			for _, board := range boards {
+				seen[board] = false
+			}
+			cfg := withLimits(c.cfg, c.bounds, c.maxCheaters)
+			cfg.ReadingOrder = true
+			lay, err := CountLayers(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for n := int64(0); n < int64(len(boards)); n++ {
+				p, err := UnrankBoard(lay, big.NewInt(n))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the way -unrank prints it, after the count, and -rank
+				// reads it back
+				p, err = ParseBoard(fmt.Sprintf("DONE! total %v\nboard %v:\n%v\n", len(boards), n, strings.Join(p, "\n")))
+				if err != nil {
+					t.Fatal(err)
+				}
+				// the brute force has them in string order too
+				board := strings.Join(p, "\n")
+				if board != boards[n] {
+					t.Fatalf("board %v is\n%v\nbut the brute force has\n%v", n, board, boards[n])
+				}
+				seen[board] = true
+				if rank, err := RankBoard(lay, p); err != nil || rank == nil || rank.Int64() != n {
+					t.Fatalf("board %v came back with rank %v, %v", n, rank, err)
+				}
+			}
+			for _, n := range []int64{-1, int64(len(boards))} {
+				if _, err := UnrankBoard(lay, big.NewInt(n)); err == nil {
+					t.Errorf("got a board for rank %v with %v boards", n, len(boards))
+				}
+			}
+			// one square off from a board is only a board if the brute
+			// force found it
+			for board := range seen {
+				flipped := []byte(board)
+				k := strings.LastIndexAny(board, "#.")
+				flipped[k] = '#' + '.' - flipped[k]
+				rank, err := RankBoard(lay, strings.Split(string(flipped), "\n"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := seen[string(flipped)]; (rank != nil) != ok {
+					t.Errorf("got rank %v for this board:\n%s", rank, flipped)
+				}
+			}
+			// the DP turns a wide board sideways without ReadingOrder
+			if cfg.Width > cfg.Height && cfg.Topology != Cylinder {
+				if _, err := UnrankBoard(layersFor(t, c), big.NewInt(0)); err == nil {
+					t.Errorf("got a board in reading order from a board turned sideways")
+				}
+			}
+		})
+	}
+}
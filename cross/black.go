@@ -0,0 +1,329 @@
+package cross
+
+// A black region is a piece of black squares that touch each other, the
+// way a component is a piece of white squares. With BlackBorder set, the
+// edge of the board is one more black region, along with everything that
+// touches it, so a board where every black square connects to the edge
+// has exactly one. Squares outside the shape count as the edge then, and
+// don't count at all otherwise.
+
+// BlackReach is like Reach for black squares, with a byte per square of
+// each row the DP still has to connect to: the top and bottom rows built
+// so far when the board is built out from the middle, the last row built
+// from the top, and the top row too on a torus. 0 is a white square, and
+// borderLabel is the edge of the board. It's empty unless BlackRegions is
+// set, so the DP doesn't keep it around for nothing, and the functions
+// that work with it do nothing then.
+type BlackReach string
+
+// borderLabel marks the squares of a BlackReach that connect to the edge
+const borderLabel = 255
+
+// TracksBlackRegions says whether the DP has to keep BlackReach around
+func TracksBlackRegions() bool {
+	return BlackRegions != 0
+}
+
+// CanHaveBlackRegions says whether a board with closed black regions
+// already closed off can still end up with BlackRegions of them
+func CanHaveBlackRegions(closed int) bool {
+	if BlackBorder {
+		closed += 1
+	}
+	return BlackRegions == 0 || closed <= BlackRegions
+}
+
+// HasBlackRegions says whether a finished board with regions black
+// regions has the right number of them
+func HasBlackRegions(regions int) bool {
+	return BlackRegions == 0 || regions == BlackRegions
+}
+
+// blackRegions is a union-find over the black squares of a few rows, and
+// the labels of the rows the DP had before them. Node 0 is the edge of
+// the board, label k is node k, and the squares come after those.
+type blackRegions struct {
+	parent []int
+	// squares has the squares of each row that are in a region
+	squares []Row
+	// labelled says which labels showed up
+	labelled [borderLabel]bool
+}
+
+func (r *blackRegions) find(x int) int {
+	for r.parent[x] != x {
+		r.parent[x] = r.parent[r.parent[x]]
+		x = r.parent[x]
+	}
+	return x
+}
+
+func (r *blackRegions) union(x, y int) {
+	r.parent[r.find(x)] = r.find(y)
+}
+
+func (r *blackRegions) square(k, j int) int {
+	return borderLabel + k*Width + j
+}
+
+// newRow adds a row to the union-find with nothing in it yet, joining up
+// the squares next to each other
+func (r *blackRegions) newRow(squares Row) int {
+	k := len(r.squares)
+	r.squares = append(r.squares, squares)
+	for j := 0; j < Width; j++ {
+		r.parent = append(r.parent, r.square(k, j))
+	}
+	for j := 0; j < Width; j++ {
+		next := j + 1
+		if wrapsAcross() {
+			next %= Width
+		}
+		if next < Width && squares.Bit(j) && squares.Bit(next) {
+			r.union(r.square(k, j), r.square(k, next))
+		}
+	}
+	return k
+}
+
+// add adds row as row i of the board, and returns which row of the
+// union-find it is
+func (r *blackRegions) add(row Row, i int) int {
+	squares := row.AndNot(Mask[i])
+	if BlackBorder {
+		squares = row
+	}
+	k := r.newRow(squares)
+	if !BlackBorder {
+		return k
+	}
+	edge := !wrapsDown() && (i == 0 || i == Height-1)
+	for j := 0; j < Width; j++ {
+		if !squares.Bit(j) {
+			continue
+		}
+		if Mask[i].Bit(j) || edge || !wrapsAcross() && (j == 0 || j == Width-1) {
+			r.union(r.square(k, j), 0)
+		}
+	}
+	return k
+}
+
+// addLabels adds a row the DP already had, with its labels from a
+// BlackReach
+func (r *blackRegions) addLabels(labels BlackReach) int {
+	var squares Row
+	for j := 0; j < Width; j++ {
+		if labels[j] > 0 {
+			squares = squares.WithBit(j)
+		}
+	}
+	k := r.newRow(squares)
+	for j := 0; j < Width; j++ {
+		label := int(labels[j])
+		if label == borderLabel {
+			label = 0
+		}
+		if squares.Bit(j) {
+			r.labelled[label] = true
+			r.union(r.square(k, j), label)
+		}
+	}
+	return k
+}
+
+// link joins up rows a and b, which are on top of each other
+func (r *blackRegions) link(a, b int) {
+	both := r.squares[a].And(r.squares[b])
+	for j := 0; j < Width; j++ {
+		if both.Bit(j) {
+			r.union(r.square(a, j), r.square(b, j))
+		}
+	}
+}
+
+// roots gets the regions some rows have, leaving out the edge
+func (r *blackRegions) roots(rows ...int) map[int]bool {
+	ret := make(map[int]bool)
+	for _, k := range rows {
+		for j := 0; j < Width; j++ {
+			if r.squares[k].Bit(j) {
+				ret[r.find(r.square(k, j))] = true
+			}
+		}
+	}
+	delete(ret, r.find(0))
+	return ret
+}
+
+// count is how many black regions every row and label makes, counting
+// the edge if BlackBorder is set
+func (r *blackRegions) count() int {
+	all := make([]int, len(r.squares))
+	for k := range all {
+		all[k] = k
+	}
+	roots := r.roots(all...)
+	for label := 1; label < borderLabel; label++ {
+		if r.labelled[label] && r.find(label) != r.find(0) {
+			roots[r.find(label)] = true
+		}
+	}
+	if BlackBorder {
+		return len(roots) + 1
+	}
+	return len(roots)
+}
+
+// finish labels the rows in keep as a BlackReach, in order, and says how
+// many regions got closed off since none of those rows have them
+func (r *blackRegions) finish(keep ...int) (int, BlackReach) {
+	closed := r.count() - len(r.roots(keep...))
+	if BlackBorder {
+		closed -= 1
+	}
+	labels := make(map[int]uint8)
+	ret := make([]uint8, 0, len(keep)*Width)
+	for _, k := range keep {
+		for j := 0; j < Width; j++ {
+			label := uint8(0)
+			if r.squares[k].Bit(j) {
+				root := r.find(r.square(k, j))
+				if root == r.find(0) {
+					label = borderLabel
+				} else {
+					if _, ok := labels[root]; !ok {
+						labels[root] = uint8(len(labels) + 1)
+					}
+					label = labels[root]
+				}
+			}
+			ret = append(ret, label)
+		}
+	}
+	return closed, BlackReach(ret)
+}
+
+func newBlackRegions() *blackRegions {
+	r := &blackRegions{}
+	for x := 0; x < borderLabel; x++ {
+		r.parent = append(r.parent, x)
+	}
+	return r
+}
+
+// InitBlackReach gets the BlackReach for the first row the DP adds, the
+// middle pair of rows of a board built out from the middle, or the top
+// row of one built from the top
+func InitBlackReach(row Row) BlackReach {
+	if !TracksBlackRegions() {
+		return ""
+	}
+	r := newBlackRegions()
+	if Folded() {
+		top := r.add(row, DPRow(0))
+		bottom := r.add(Opposite(row), Height-1-DPRow(0))
+		r.link(top, bottom)
+		_, reach := r.finish(top, bottom)
+		return reach
+	}
+	top := r.add(row, 0)
+	if wrapsDown() {
+		// the top row stays in it for the last row to wrap around to
+		_, reach := r.finish(top, top)
+		return reach
+	}
+	_, reach := r.finish(top)
+	return reach
+}
+
+// InitBlackMiddle gets the BlackReach for row and its opposite on either
+// side of the middle row of a board with an odd height, and how many
+// regions the middle row closes off on its own
+func InitBlackMiddle(middle, row Row) (int, BlackReach) {
+	return initBlackBand(middle, row, 1)
+}
+
+// InitBlackBand is InitBlackMiddle for row number index with nothing but
+// black rows between it and its opposite
+func InitBlackBand(row Row, index int) (int, BlackReach) {
+	return initBlackBand(MaxRow, row, index)
+}
+
+// initBlackBand stacks row, every row between it and its opposite as
+// middle, and its opposite, with row as row number index
+func initBlackBand(middle, row Row, index int) (int, BlackReach) {
+	if !TracksBlackRegions() {
+		return 0, ""
+	}
+	r := newBlackRegions()
+	top := r.add(row, DPRow(index))
+	last := top
+	for i := DPRow(index) + 1; i < Height-1-DPRow(index); i++ {
+		next := r.add(middle, i)
+		r.link(last, next)
+		last = next
+	}
+	bottom := r.add(Opposite(row), Height-1-DPRow(index))
+	r.link(last, bottom)
+	return r.finish(top, bottom)
+}
+
+// ApplyBlackReach adds row as row number index, on top of the frontier
+// when the board is built out from the middle, and its opposite under
+// it. It returns how many regions got closed off, along with the new
+// BlackReach.
+func ApplyBlackReach(reach BlackReach, row Row, index int) (int, BlackReach) {
+	if !TracksBlackRegions() {
+		return 0, ""
+	}
+	r := newBlackRegions()
+	old := r.addLabels(reach[:Width])
+	if Folded() {
+		oldBottom := r.addLabels(reach[Width:])
+		top := r.add(row, DPRow(index))
+		bottom := r.add(Opposite(row), Height-1-DPRow(index))
+		r.link(old, top)
+		r.link(oldBottom, bottom)
+		return r.finish(top, bottom)
+	}
+	next := r.add(row, index)
+	r.link(old, next)
+	if wrapsDown() {
+		return r.finish(next, r.addLabels(reach[Width:]))
+	}
+	return r.finish(next)
+}
+
+// EndBlackRegions is how many black regions a board has once the DP is
+// done with it, given the ones it closed off along the way. On a torus
+// the two rows left in the BlackReach wrap around to touch each other.
+func EndBlackRegions(reach BlackReach, closed int) int {
+	if !TracksBlackRegions() {
+		return 0
+	}
+	r := newBlackRegions()
+	a := r.addLabels(reach[:Width])
+	if len(reach) > Width {
+		b := r.addLabels(reach[Width:])
+		if wrapsDown() {
+			r.link(a, b)
+		}
+	}
+	return closed + r.count()
+}
+
+// BoardBlackRegions is how many black regions a whole board has
+func BoardBlackRegions(board []Row) int {
+	r := newBlackRegions()
+	for i, row := range board {
+		k := r.add(row, i)
+		if i > 0 {
+			r.link(k-1, k)
+		}
+	}
+	if wrapsDown() {
+		r.link(len(board)-1, 0)
+	}
+	return r.count()
+}
@@ -0,0 +1,171 @@
+package cross
+
+import "testing"
+
+// boardOf initializes cfg for the board with the given rows, # for black,
+// and gets it back as Rows. It has to be at least as tall as it is wide,
+// or it would get stored transposed.
+func boardOf(t *testing.T, cfg Config, rows ...string) []Row {
+	t.Helper()
+	cfg.Width, cfg.Height = len(rows[0]), len(rows)
+	if err := Init(cfg); err != nil {
+		t.Fatal(err)
+	}
+	board := make([]Row, len(rows))
+	for i, line := range rows {
+		for j := range line {
+			if line[j] == '#' {
+				board[i] = board[i].WithBit(j)
+			}
+		}
+	}
+	return board
+}
+
+var corners = []string{
+	"#...#",
+	".....",
+	"..#..",
+	".....",
+	"#...#",
+}
+
+func TestBoardEntries(t *testing.T) {
+	board := boardOf(t, Config{MinLength: 1}, corners...)
+	if !HasValidRuns(board) {
+		t.Errorf("runs should be fine with MinLength 1")
+	}
+	if n := BoardEntries(board); n != 12 {
+		t.Errorf("got %v entries, want 12", n)
+	}
+	board = boardOf(t, Config{MinLength: 2}, corners...)
+	if !HasValidRuns(board) {
+		t.Errorf("runs should be fine with MinLength 2")
+	}
+	board = boardOf(t, Config{MinLength: 3}, corners...)
+	if HasValidRuns(board) {
+		t.Errorf("the middle row has runs of 2, shorter than MinLength 3")
+	}
+	board = boardOf(t, Config{MinLength: 1, MaxLength: 4}, corners...)
+	if HasValidRuns(board) {
+		t.Errorf("the second row is longer than MaxLength 4")
+	}
+}
+
+func TestHasBlackBorder(t *testing.T) {
+	rows := []string{
+		"###",
+		"...",
+		"...",
+	}
+	if !HasBlackBorder(boardOf(t, Config{MinLength: 1}, rows...)) {
+		t.Errorf("top side is black")
+	}
+	if !HasBlackBorder(boardOf(t, Config{MinLength: 1, Topology: Cylinder}, rows...)) {
+		t.Errorf("top side is black, and a cylinder only wraps left to right")
+	}
+	if HasBlackBorder(boardOf(t, Config{MinLength: 1, Topology: Torus}, rows...)) {
+		t.Errorf("a torus doesn't have sides")
+	}
+	if HasBlackBorder(boardOf(t, Config{MinLength: 1}, corners...)) {
+		t.Errorf("no side is all black")
+	}
+	shaped := Config{MinLength: 1, Shape: [][]bool{{true, false, true}, {false, false, false}, {true, false, true}}}
+	if HasBlackBorder(boardOf(t, shaped, "###", "...", "#.#")) {
+		t.Errorf("a shape doesn't have sides")
+	}
+}
+
+func TestBoardPieces(t *testing.T) {
+	board := boardOf(t, Config{MinLength: 1}, corners...)
+	if n := WhiteComponents(board); n != 1 {
+		t.Errorf("got %v white components, want 1", n)
+	}
+	if n := BoardBlackRegions(board); n != 5 {
+		t.Errorf("got %v black regions, want 5", n)
+	}
+	if cuts := ArticulationCells(board); len(cuts) != 0 {
+		t.Errorf("got cuts %v, want none", cuts)
+	}
+	// the corners touch the edge, which makes one more region along with
+	// them, and the middle one is on its own
+	board = boardOf(t, Config{MinLength: 1, BlackRegions: 2, BlackBorder: true}, corners...)
+	if n := BoardBlackRegions(board); n != 2 {
+		t.Errorf("got %v black regions counting the border, want 2", n)
+	}
+
+	board = boardOf(t, Config{MinLength: 1, Components: AnyComponents},
+		".#.",
+		"...",
+		".#.",
+	)
+	if cuts := ArticulationCells(board); len(cuts) != 3 {
+		t.Errorf("got cuts %v, want the three squares of the middle row", cuts)
+	}
+	board = boardOf(t, Config{MinLength: 1, Components: AnyComponents},
+		".#.",
+		"###",
+		".#.",
+	)
+	if n := WhiteComponents(board); n != 4 {
+		t.Errorf("got %v white components, want 4", n)
+	}
+}
+
+func TestCheaters(t *testing.T) {
+	// each corner can be white without adding or taking away an entry,
+	// but a white middle square would join two entries into one
+	board := boardOf(t, Config{MinLength: 1}, corners...)
+	if cheaters := Cheaters(board); len(cheaters) != 4 {
+		t.Errorf("got cheaters %v, want the four corners", cheaters)
+	}
+}
+
+func TestPatterns(t *testing.T) {
+	cfg := Config{MinLength: 1, Forbidden: []Pattern{{"##"}}, Motif: Pattern{"#", "."}}
+	board := boardOf(t, cfg, corners...)
+	if HasForbidden(board) {
+		t.Errorf("no two black squares are next to each other")
+	}
+	// under the top corners and the middle square, but nothing's under
+	// the bottom corners
+	if n := BoardMotifs(board); n != 3 {
+		t.Errorf("got %v motifs, want 3", n)
+	}
+	// on a torus, the squares under the bottom corners are the top ones,
+	// and the corners are next to each other across the edges
+	cfg.Topology = Torus
+	board = boardOf(t, cfg, corners...)
+	if n := BoardMotifs(board); n != 3 {
+		t.Errorf("got %v motifs on a torus, want 3", n)
+	}
+	if !HasForbidden(board) {
+		t.Errorf("the corners are side by side across the edge of a torus")
+	}
+	cfg.Forbidden = []Pattern{{"#", "#"}}
+	if board = boardOf(t, cfg, corners...); !HasForbidden(board) {
+		t.Errorf("the corners are on top of each other across the edge of a torus")
+	}
+}
+
+// count.go prints the count before the board, and -sample prints more
+// than one board, so only the first one after a header counts
+func TestParseBoard(t *testing.T) {
+	p, err := ParseBoard("DONE! 3x3 total 2\nboard 1:\n#..\n...\n..#\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 3 || p[0] != "#.." || p[2] != "..#" {
+		t.Errorf("got %q, want the board after the header", p)
+	}
+	p, err = ParseBoard("sample 1:\n...\n...\n...\nsample 2:\n#..\n...\n..#\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 3 || p[0] != "..." {
+		t.Errorf("got %q, want the first sample", p)
+	}
+	if _, err := ParseBoard("DONE! 3x3 total 2\n"); err == nil {
+		t.Errorf("got a board out of just the count")
+	}
+}
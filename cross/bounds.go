@@ -0,0 +1,58 @@
+package cross
+
+import (
+	"math"
+)
+
+// Bounds limits how many black squares and words a board can have, for
+// outlets with a cap on either. A max of 0 means no limit, like MaxLength
+type Bounds struct {
+	MinBlacks int
+	MaxBlacks int
+	MinWords  int
+	MaxWords  int
+}
+
+// Blacks says whether there are any bounds on black squares
+func (b Bounds) Blacks() bool {
+	return b.MinBlacks > 0 || b.MaxBlacks > 0
+}
+
+// Words says whether there are any bounds on words
+func (b Bounds) Words() bool {
+	return b.MinWords > 0 || b.MaxWords > 0
+}
+
+// BlackRange is how many black squares a partial board can have so far
+// and still end up in bounds, if the rest of it has room for up to more
+func (b Bounds) BlackRange(more int) (int, int) {
+	return boundRange(b.MinBlacks, b.MaxBlacks, more)
+}
+
+// WordRange is BlackRange for words
+func (b Bounds) WordRange(more int) (int, int) {
+	return boundRange(b.MinWords, b.MaxWords, more)
+}
+
+func boundRange(min, max, more int) (int, int) {
+	if max == 0 {
+		max = math.MaxInt
+	}
+	return min - more, max
+}
+
+// CanReach says whether a partial board with blacks black squares and
+// words words so far could still end up in bounds, if the rest of it
+// adds up to moreBlacks and moreWords. Pass 0 for those to check a whole
+// board
+func (b Bounds) CanReach(blacks, words, moreBlacks, moreWords int) bool {
+	minBlacks, maxBlacks := b.BlackRange(moreBlacks)
+	minWords, maxWords := b.WordRange(moreWords)
+	return minBlacks <= blacks && blacks <= maxBlacks && minWords <= words && words <= maxWords
+}
+
+// MostEntries is the most entries a line of length squares can have,
+// with a black square between each of them
+func MostEntries(length int) int {
+	return (length + 1) / (MinLength + 1)
+}
@@ -0,0 +1,106 @@
+package cross
+
+// A cheater is a black square that doesn't change the word count, since
+// turning it white wouldn't add or take away any entries. Each line
+// through it gets one more entry from the square, minus the runs of white
+// squares it joins up. A square on its own isn't an entry though, unless
+// MinLength is 1.
+
+// whiteDelta is how many more entries a line through a black square gets
+// when the square turns white, given how many different runs of white
+// squares touch it in that line
+func whiteDelta(runs int) int {
+	if runs == 0 && MinLength > 1 {
+		return 0
+	}
+	return 1 - runs
+}
+
+// touchingRuns is how many different runs of white squares touch a black
+// square in a line, given whether the squares on each side of it are
+// white. They're the same run if the line wraps around and only has the
+// one black square
+func touchingRuns(before, after, onlyBlack bool) int {
+	runs := 0
+	if before {
+		runs++
+	}
+	if after {
+		runs++
+	}
+	if runs == 2 && onlyBlack {
+		runs = 1
+	}
+	return runs
+}
+
+// acrossRuns is touchingRuns for the across line through square j of row
+func acrossRuns(row Row, j int) int {
+	left, right := j-1, j+1
+	if wrapsAcross() {
+		left, right = (left+Width)%Width, right%Width
+	}
+	return touchingRuns(
+		left >= 0 && !row.Bit(left),
+		right < Width && !row.Bit(right),
+		wrapsAcross() && row.OnesCount() == 1,
+	)
+}
+
+// RowCheaters is how many cheaters row has, given the rows on either side
+// of it, where an edge works like an all-black row. mask has the squares
+// of row that are outside the shape, which don't count. It only looks at
+// three rows, so it can't see a column that wraps around with a single
+// black square, see Cheaters.
+func RowCheaters(a, row, b, mask Row) int {
+	count := 0
+	for j := 0; j < Width; j++ {
+		if !row.Bit(j) || mask.Bit(j) {
+			continue
+		}
+		down := touchingRuns(!a.Bit(j), !b.Bit(j), false)
+		if whiteDelta(acrossRuns(row, j))+whiteDelta(down) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// Cheaters finds the cheaters in a whole board, as a row and a column
+// each
+func Cheaters(board []Row) [][2]int {
+	// blacks[j] is how many black squares column j has, to see which
+	// columns wrap around with just one
+	blacks := make([]int, Width)
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			if board[i].Bit(j) {
+				blacks[j]++
+			}
+		}
+	}
+	var ret [][2]int
+	for i := 0; i < Height; i++ {
+		above, below := MaxRow, MaxRow
+		if i > 0 {
+			above = board[i-1]
+		} else if wrapsDown() {
+			above = board[Height-1]
+		}
+		if i < Height-1 {
+			below = board[i+1]
+		} else if wrapsDown() {
+			below = board[0]
+		}
+		for j := 0; j < Width; j++ {
+			if !board[i].Bit(j) || Mask[i].Bit(j) {
+				continue
+			}
+			down := touchingRuns(!above.Bit(j), !below.Bit(j), wrapsDown() && blacks[j] == 1)
+			if whiteDelta(acrossRuns(board[i], j))+whiteDelta(down) == 0 {
+				ret = append(ret, [2]int{i, j})
+			}
+		}
+	}
+	return ret
+}
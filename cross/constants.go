@@ -1,7 +1,38 @@
 package cross
 
-// Assume BoardSize is odd
-const BoardSize = 21
-const HalfBoardSize = (BoardSize + 1) / 2
-const MaxVal = 1 << BoardSize
-const MaxRow = MaxVal - 1
+// Rows are RowWords uint64s, so this is as wide as we can go
+const MaxWidth = RowWords * 64
+
+// These all follow from the Config passed to Init. Width and Height are
+// the board as the DP sees it, which is the transpose of what was asked
+// for if Transposed is set, and BoardSymmetry is the full set of
+// symmetries of that board, which decides the BoardStrategy. HalfHeight
+// is how many rows get picked, which is all of them unless the strategy
+// builds out from the middle. Then it counts the middle row when Height
+// is odd, and is exactly half the rows when it's even. MinLength is the
+// shortest run of white squares allowed, and MaxLength the longest, or 0
+// if there's no limit. BoardTopology says which edges wrap around, and
+// a cylinder is never transposed, so it always wraps across the rows.
+// Components is how many pieces the white squares have to be in, or
+// AnyComponents, and BlackRegions is how many black regions there have to
+// be, or 0 for any number, with the edge of the board as one of them if
+// BlackBorder is set. Biconnected rules out cuts, see Cuts. MotifCount is
+// how many times the motif has to show up, or 0 for at least once
+var Width int
+var Height int
+var HalfHeight int
+var Transposed bool
+var BoardSymmetry Symmetry
+var BoardStrategy Strategy
+var BoardTopology Topology
+var MaxRow Row
+var MinLength int
+var MaxLength int
+var Components int
+var BlackRegions int
+var BlackBorder bool
+var Biconnected bool
+var MotifCount int
+
+// AnyComponents lets the white squares be in any number of pieces
+const AnyComponents = -1
@@ -0,0 +1,546 @@
+package cross
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// A cut is a white square that splits the white squares it's with into
+// more pieces when it turns black, the square that everything on one side
+// has to go through to get to the other. With Biconnected set, boards
+// can't have any, so no one square holds two parts of the board together.
+
+// Cuts is like Reach for cuts, with enough of the white squares the DP
+// has added so far to tell where cuts can still turn up. It's a graph on
+// the white squares of the same rows BlackReach has, where each block, a
+// piece that no one square splits, is a cycle through the squares in it
+// that still matter: the ones in those rows, and the cuts that aren't,
+// which stay on as extra nodes. It's empty unless Biconnected is set.
+type Cuts string
+
+// noNode is a black square in the rows Cuts keeps
+const noNode = 0xffff
+
+// cutGraph is the white squares of a few rows, and whatever Cuts kept of
+// the rows before them
+type cutGraph struct {
+	adj [][]int
+	// rows has the node for each square of each row, or -1 for black
+	rows [][]int
+}
+
+func (g *cutGraph) node() int {
+	g.adj = append(g.adj, nil)
+	return len(g.adj) - 1
+}
+
+func (g *cutGraph) edge(a, b int) {
+	if a == b {
+		return
+	}
+	for _, c := range g.adj[a] {
+		if c == b {
+			return
+		}
+	}
+	g.adj[a] = append(g.adj[a], b)
+	g.adj[b] = append(g.adj[b], a)
+}
+
+// cycle joins nodes up in a cycle, which is just an edge for two of them
+func (g *cutGraph) cycle(nodes []int) {
+	if len(nodes) < 2 {
+		return
+	}
+	for k := range nodes {
+		g.edge(nodes[k], nodes[(k+1)%len(nodes)])
+	}
+}
+
+// add adds the white squares of row, and returns which row of the graph
+// it is
+func (g *cutGraph) add(row Row) int {
+	nodes := make([]int, Width)
+	for j := range nodes {
+		nodes[j] = -1
+		if !row.Bit(j) {
+			nodes[j] = g.node()
+		}
+	}
+	for j := 0; j < Width; j++ {
+		next := j + 1
+		if wrapsAcross() {
+			next %= Width
+		}
+		if next < Width && nodes[j] >= 0 && nodes[next] >= 0 {
+			g.edge(nodes[j], nodes[next])
+		}
+	}
+	g.rows = append(g.rows, nodes)
+	return len(g.rows) - 1
+}
+
+// link joins up rows a and b, which are on top of each other
+func (g *cutGraph) link(a, b int) {
+	for j := 0; j < Width; j++ {
+		if g.rows[a][j] >= 0 && g.rows[b][j] >= 0 {
+			g.edge(g.rows[a][j], g.rows[b][j])
+		}
+	}
+}
+
+// addCuts adds the graph c keeps, and returns the rows of the graph it
+// has, in order
+func (g *cutGraph) addCuts(c Cuts) []int {
+	data := make([]int, len(c)/2)
+	for k := range data {
+		data[k] = int(binary.LittleEndian.Uint16([]byte(c[2*k:])))
+	}
+	numRows := data[0]
+	positions := data[1 : 1+numRows*Width]
+	data = data[1+numRows*Width:]
+	first := len(g.adj)
+	for k := 0; k < data[0]; k++ {
+		g.node()
+	}
+	var ret []int
+	for k := 0; k < numRows; k++ {
+		nodes := make([]int, Width)
+		for j := range nodes {
+			nodes[j] = -1
+			if p := positions[k*Width+j]; p != noNode {
+				nodes[j] = first + p
+			}
+		}
+		g.rows = append(g.rows, nodes)
+		ret = append(ret, len(g.rows)-1)
+	}
+	for data = data[1:]; len(data) > 0; {
+		size := data[0]
+		block := make([]int, size)
+		for k := range block {
+			block[k] = first + data[1+k]
+		}
+		g.cycle(block)
+		data = data[1+size:]
+	}
+	return ret
+}
+
+// blocks splits the graph into its blocks, and says which nodes are cuts
+func (g *cutGraph) blocks() ([][]int, []bool) {
+	order := make([]int, len(g.adj))
+	low := make([]int, len(g.adj))
+	cut := make([]bool, len(g.adj))
+	var blocks [][]int
+	var edges [][2]int
+	count := 0
+	var visit func(v, parent int)
+	visit = func(v, parent int) {
+		count += 1
+		order[v], low[v] = count, count
+		children := 0
+		for _, w := range g.adj[v] {
+			if order[w] == 0 {
+				children += 1
+				edges = append(edges, [2]int{v, w})
+				visit(w, v)
+				low[v] = min(low[v], low[w])
+				if low[w] < order[v] {
+					continue
+				}
+				// everything under w only gets to the rest through v
+				if parent >= 0 || children > 1 {
+					cut[v] = true
+				}
+				seen := make(map[int]bool)
+				var block []int
+				for {
+					e := edges[len(edges)-1]
+					edges = edges[:len(edges)-1]
+					for _, x := range e {
+						if !seen[x] {
+							seen[x] = true
+							block = append(block, x)
+						}
+					}
+					if e == [2]int{v, w} {
+						break
+					}
+				}
+				blocks = append(blocks, block)
+			} else if w != parent && order[w] < order[v] {
+				edges = append(edges, [2]int{v, w})
+				low[v] = min(low[v], order[w])
+			}
+		}
+	}
+	for v := range g.adj {
+		if order[v] == 0 {
+			visit(v, -1)
+		}
+	}
+	return blocks, cut
+}
+
+// components labels each node with the piece of the graph it's in
+func (g *cutGraph) components() []int {
+	ret := make([]int, len(g.adj))
+	for k := range ret {
+		ret[k] = -1
+	}
+	for v := range g.adj {
+		if ret[v] >= 0 {
+			continue
+		}
+		ret[v] = v
+		queue := []int{v}
+		for len(queue) > 0 {
+			x := queue[0]
+			queue = queue[1:]
+			for _, y := range g.adj[x] {
+				if ret[y] < 0 {
+					ret[y] = v
+					queue = append(queue, y)
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// hasCuts says whether any square of the graph is a cut
+func (g *cutGraph) hasCuts() bool {
+	_, cut := g.blocks()
+	for _, c := range cut {
+		if c {
+			return true
+		}
+	}
+	return false
+}
+
+// finish gets the Cuts for the rows in keep, in order. It fails if the
+// graph has a cut that nothing can fix any more: one in a piece that
+// doesn't reach those rows, or one that a block hangs off without any
+// other squares in those rows, since nothing else can join on to it.
+func (g *cutGraph) finish(keep ...int) (Cuts, bool) {
+	terminal := make([]bool, len(g.adj))
+	for _, k := range keep {
+		for _, v := range g.rows[k] {
+			if v >= 0 {
+				terminal[v] = true
+			}
+		}
+	}
+	blocks, cut := g.blocks()
+	component := g.components()
+	open := make(map[int]bool)
+	blockCount := make(map[int]int)
+	for v := range g.adj {
+		if terminal[v] {
+			open[component[v]] = true
+		}
+	}
+	for v := range g.adj {
+		if cut[v] && !open[component[v]] {
+			return "", false
+		}
+	}
+	for _, block := range blocks {
+		blockCount[component[block[0]]] += 1
+	}
+
+	// what's left of each block is the squares in it that matter, where
+	// a block of a piece that only touches the rows at one square keeps
+	// an extra node, since nothing else can join on at that square
+	var kept [][]int
+	extra := len(g.adj)
+	for _, block := range blocks {
+		if !open[component[block[0]]] {
+			continue
+		}
+		var matter []int
+		cuts, terminals := 0, 0
+		for _, v := range block {
+			if cut[v] {
+				cuts += 1
+			} else if terminal[v] {
+				terminals += 1
+			}
+			if cut[v] || terminal[v] {
+				matter = append(matter, v)
+			}
+		}
+		if blockCount[component[block[0]]] > 1 && cuts == 1 && terminals == 0 {
+			return "", false
+		}
+		if len(matter) == 1 {
+			matter = append(matter, extra)
+			extra += 1
+		}
+		kept = append(kept, matter)
+	}
+
+	// a block between just two cuts outside the rows that are each in
+	// one other block can go, since they split everything up the same way
+	inBlocks := make([]int, extra)
+	for _, block := range kept {
+		for _, v := range block {
+			inBlocks[v] += 1
+		}
+	}
+	same := make([]int, extra)
+	for k := range same {
+		same[k] = k
+	}
+	var find func(x int) int
+	find = func(x int) int {
+		if same[x] != x {
+			same[x] = find(same[x])
+		}
+		return same[x]
+	}
+	blocks = nil
+	for _, block := range kept {
+		if len(block) == 2 && block[0] < len(g.adj) && block[1] < len(g.adj) &&
+			!terminal[block[0]] && !terminal[block[1]] && inBlocks[block[0]] == 2 && inBlocks[block[1]] == 2 {
+			same[find(block[0])] = find(block[1])
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+
+	// number the nodes the same way every time: the squares in the rows
+	// in the order they come, and then the rest by how they split up the
+	// squares in the rows
+	id := make(map[int]int)
+	positions := make([]int, len(keep)*Width)
+	for k, row := range keep {
+		for j, v := range g.rows[row] {
+			positions[k*Width+j] = noNode
+			if v >= 0 {
+				if _, ok := id[v]; !ok {
+					id[v] = len(id)
+				}
+				positions[k*Width+j] = id[v]
+			}
+		}
+	}
+	var others []int
+	seen := make(map[int]bool)
+	for k := range blocks {
+		for i, v := range blocks[k] {
+			if _, ok := id[v]; !ok {
+				v = find(v)
+				blocks[k][i] = v
+				if !seen[v] {
+					seen[v] = true
+					others = append(others, v)
+				}
+			}
+		}
+	}
+	keys := make(map[int]string)
+	for _, v := range others {
+		keys[v] = splitKey(blocks, v, positions, id)
+	}
+	sort.Slice(others, func(a, b int) bool { return keys[others[a]] < keys[others[b]] })
+	for _, v := range others {
+		id[v] = len(id)
+	}
+
+	var encoded [][]int
+	for _, block := range blocks {
+		ids := make([]int, len(block))
+		for k, v := range block {
+			ids[k] = id[v]
+		}
+		sort.Ints(ids)
+		encoded = append(encoded, ids)
+	}
+	sort.Slice(encoded, func(a, b int) bool {
+		for k := 0; k < len(encoded[a]) && k < len(encoded[b]); k++ {
+			if encoded[a][k] != encoded[b][k] {
+				return encoded[a][k] < encoded[b][k]
+			}
+		}
+		return len(encoded[a]) < len(encoded[b])
+	})
+	data := append([]int{len(keep)}, positions...)
+	data = append(data, len(id))
+	for _, ids := range encoded {
+		data = append(data, len(ids))
+		data = append(data, ids...)
+	}
+	b := make([]byte, 0, 2*len(data))
+	for _, x := range data {
+		b = binary.LittleEndian.AppendUint16(b, uint16(x))
+	}
+	return Cuts(b), true
+}
+
+// splitKey describes how taking out node v splits up the squares in the
+// rows, where blocks are cycles like in Cuts and positions says where the
+// square with each id is
+func splitKey(blocks [][]int, v int, positions []int, id map[int]int) string {
+	g := &cutGraph{}
+	nodes := make(map[int]int)
+	for _, block := range blocks {
+		var cycle []int
+		for _, x := range block {
+			if x == v {
+				continue
+			}
+			if _, ok := nodes[x]; !ok {
+				nodes[x] = g.node()
+			}
+			cycle = append(cycle, nodes[x])
+		}
+		// a block with v in it is still in one piece without it
+		for k := 1; k < len(cycle); k++ {
+			g.edge(cycle[k-1], cycle[k])
+		}
+	}
+	component := g.components()
+	// pieces has the piece each square in the rows ends up in, by id
+	pieces := make(map[int]int)
+	for x, node := range nodes {
+		if square, ok := id[x]; ok {
+			pieces[square] = component[node]
+		}
+	}
+	sets := make(map[int][]byte)
+	for p, square := range positions {
+		c, ok := pieces[square]
+		if square == noNode || !ok {
+			continue
+		}
+		if sets[c] == nil {
+			sets[c] = make([]byte, (len(positions)+7)/8)
+		}
+		sets[c][p/8] |= 1 << (p % 8)
+	}
+	var parts []string
+	for _, set := range sets {
+		parts = append(parts, string(set))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, "|")
+}
+
+// InitCuts gets the Cuts for the first row the DP adds, like
+// InitBlackReach
+func InitCuts(row Row) Cuts {
+	if !Biconnected {
+		return ""
+	}
+	g := &cutGraph{}
+	top := g.add(row)
+	var cuts Cuts
+	switch {
+	case Folded():
+		bottom := g.add(Opposite(row))
+		g.link(top, bottom)
+		cuts, _ = g.finish(top, bottom)
+	case wrapsDown():
+		cuts, _ = g.finish(top, top)
+	default:
+		cuts, _ = g.finish(top)
+	}
+	return cuts
+}
+
+// InitMiddleCuts gets the Cuts for row and its opposite on either side of
+// the middle row of a board with an odd height, and says whether the
+// board can still be Biconnected
+func InitMiddleCuts(middle, row Row) (Cuts, bool) {
+	return initBandCuts(middle, row, 1)
+}
+
+// InitBandCuts is InitMiddleCuts for row number index with nothing but
+// black rows between it and its opposite
+func InitBandCuts(row Row, index int) (Cuts, bool) {
+	return initBandCuts(MaxRow, row, index)
+}
+
+// initBandCuts stacks row, every row between it and its opposite as
+// middle, and its opposite, with row as row number index
+func initBandCuts(middle, row Row, index int) (Cuts, bool) {
+	if !Biconnected {
+		return "", true
+	}
+	g := &cutGraph{}
+	top := g.add(row)
+	last := top
+	for i := DPRow(index) + 1; i < Height-1-DPRow(index); i++ {
+		next := g.add(middle)
+		g.link(last, next)
+		last = next
+	}
+	bottom := g.add(Opposite(row))
+	g.link(last, bottom)
+	return g.finish(top, bottom)
+}
+
+// ApplyCuts adds row on top of the frontier, and its opposite under it
+// when the board is built out from the middle, like ApplyBlackReach. It
+// says whether the board can still be Biconnected.
+func ApplyCuts(cuts Cuts, row Row) (Cuts, bool) {
+	if !Biconnected {
+		return "", true
+	}
+	g := &cutGraph{}
+	old := g.addCuts(cuts)
+	next := g.add(row)
+	g.link(old[0], next)
+	switch {
+	case Folded():
+		bottom := g.add(Opposite(row))
+		g.link(old[1], bottom)
+		return g.finish(next, bottom)
+	case wrapsDown():
+		return g.finish(next, old[1])
+	}
+	return g.finish(next)
+}
+
+// EndCuts says whether a board the DP is done with has no cuts. On a
+// torus the two rows left in the Cuts wrap around to touch each other.
+func EndCuts(cuts Cuts) bool {
+	if !Biconnected {
+		return true
+	}
+	g := &cutGraph{}
+	rows := g.addCuts(cuts)
+	if wrapsDown() && len(rows) == 2 {
+		g.link(rows[0], rows[1])
+	}
+	return !g.hasCuts()
+}
+
+// ArticulationCells finds the cuts in a whole board, as a row and a
+// column each
+func ArticulationCells(board []Row) [][2]int {
+	g := &cutGraph{}
+	for i, row := range board {
+		g.add(row)
+		if i > 0 {
+			g.link(i-1, i)
+		}
+	}
+	if wrapsDown() {
+		g.link(len(board)-1, 0)
+	}
+	_, cut := g.blocks()
+	var ret [][2]int
+	for i := range board {
+		for j, v := range g.rows[i] {
+			if v >= 0 && cut[v] {
+				ret = append(ret, [2]int{i, j})
+			}
+		}
+	}
+	return ret
+}
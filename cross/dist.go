@@ -3,86 +3,219 @@ package cross
 import (
 	"fmt"
 	"github.com/golang-collections/go-datastructures/bitarray"
+	"math/bits"
 	"strings"
 )
 
-const DistSize = BoardSize/4 + 2
+// DistBits is the number of bits each column takes up in a Dist, and
+// DistSize the number of bytes in one. Both are set by Init
+var DistBits int
+var DistSize int
 
-//       0   1   2   3
-// key: 000 100 010 001
-func GetDistAtIndex(dist [DistSize]uint8, i uint8) uint8 {
-	return ((0x3 << (2 * (i % 4))) & dist[i/4]) >> (2 * (i % 4))
+// Dist tracks how far each column of the top half is from its last
+// black square. Once a column has a black square its value counts down
+// from MinLength (just placed) to 0 (the run above it is long enough).
+//
+// When the board gets built out from the middle, a column that has been
+// white all the way up from the middle is open. Its run carries on down
+// the bottom half, which by symmetry is its opposite column read upwards
+// (see oppositeColumn), so an open column also remembers whether the
+// opposite column is still open (distOpen) or closed after u squares
+// (distOpen+1+u). Once the run through the middle is long enough however
+// the rest of it goes, an open column is 0 like a closed one, except on a
+// torus, where the runs wrap around.
+//
+// It's a string so it can be used in map keys no matter what the board
+// size is.
+type Dist string
+
+func distOpen() uint8 {
+	return uint8(MinLength) + 1
+}
+
+// initDistSize works out how many bits the values above need, where u
+// never has to go past MinLength
+func initDistSize() {
+	DistBits = bits.Len(uint(2*MinLength + 2))
+	DistSize = (Width*DistBits+7)/8 + 1
+}
+
+func GetDistAtIndex(dist Dist, i int) uint8 {
+	bit := i * DistBits
+	val := uint(dist[bit/8]) | uint(dist[bit/8+1])<<8
+	return uint8((val >> uint(bit%8)) & (1<<uint(DistBits) - 1))
 }
 
-func SetDistAtIndex(dist *[DistSize]uint8, i uint8, val uint8) {
-	(*dist)[i/4] |= (val << (2 * (i % 4)))
+func SetDistAtIndex(dist []uint8, i int, val uint8) {
+	bit := i * DistBits
+	shifted := uint(val) << uint(bit%8)
+	dist[bit/8] |= uint8(shifted)
+	dist[bit/8+1] |= uint8(shifted >> 8)
 }
 
-func DistToString(dist [DistSize]uint8) string {
+func DistToString(dist Dist) string {
 	var b strings.Builder
-	for i := uint8(0); i < BoardSize; i++ {
+	for i := 0; i < Width; i++ {
 		fmt.Fprintf(&b, "%2d ", GetDistAtIndex(dist, i))
 	}
 	return b.String()
 }
 
-func InitDist(row1, row2, row3 uint32) [DistSize]uint8 {
-	var ret [DistSize]uint8
-	arr1, arr2, arr3 := RowToArray(row1), RowToArray(row2), RowToArray(row3)
-	for i := uint8(0); i < BoardSize; i++ {
-		cur := 0
-		if arr3[i] {
-			cur = 3
-		} else if arr2[i] {
-			cur = 2
-		} else if arr1[i] {
-			cur = 1
-		}
-		SetDistAtIndex(&ret, uint8(i), uint8(cur))
+// InitDist is the dist before any rows have been added, where every
+// column is open. Boards built from the top start out right under the
+// edge instead, which works just like a black square. On a torus the
+// top runs carry on from the bottom, so they can be any length, which
+// TopRuns keeps track of
+func InitDist() Dist {
+	ret := make([]uint8, DistSize)
+	start := distOpen()
+	if !Folded() && wrapsDown() {
+		start = 0
+	} else if !Folded() {
+		start = uint8(MinLength)
 	}
-	return ret
+	for i := 0; i < Width; i++ {
+		SetDistAtIndex(ret, i, start)
+	}
+	return Dist(ret)
 }
 
-func IsValidDist(dist [DistSize]uint8) bool {
-	for i := uint8(0); i < BoardSize; i++ {
+// middleRunLength gets the length of the down run through the middle of
+// a column, given how many of its squares are in the top half of the
+// column and how many are in the top half of the opposite column
+func middleRunLength(top, opposite int) int {
+	length := top + opposite - Height%2
+	if length < 0 {
+		return 0
+	}
+	return length
+}
+
+func isValidLength(length int) bool {
+	return length == 0 || length >= MinLength
+}
+
+// IsValidDist checks that the last row of dist could be the top row of
+// the board, so no column ends in a run that's too short
+func IsValidDist(dist Dist) bool {
+	open := distOpen()
+	for i := 0; i < Width; i++ {
 		curDist := GetDistAtIndex(dist, i)
-		if curDist != 0 && curDist != 3 {
+		switch {
+		case curDist == open:
+			// white from top to bottom
+			if !isValidLength(middleRunLength(HalfHeight, HalfHeight)) {
+				return false
+			}
+		case curDist > open:
+			u := int(curDist - open - 1)
+			if !isValidLength(middleRunLength(HalfHeight, u)) {
+				return false
+			}
+		case curDist != 0 && curDist != uint8(MinLength):
 			return false
 		}
 	}
 	return true
 }
 
-func GetPossibleNextRowsForDist(dist [DistSize]uint8) bitarray.BitArray {
-	// don't want any zeros wherever any of the dists are > 0
-	// get the key into oneonebitarraymap
-	var key uint32 = 0
-	for i := uint8(0); i < BoardSize; i++ {
+// GetPossibleNextRowsForDist gets the rows that can go on top of dist as
+// row number index, counting out from the middle. A column and its
+// opposite can close at the same time and still make a short run, so
+// this lets through a few rows that ApplyDist then rejects.
+func GetPossibleNextRowsForDist(dist Dist, index int) bitarray.BitArray {
+	return AvoidOneOne(distKey(dist, index))
+}
+
+// CanCloseAllForDist says whether an all-black row can go on top of dist
+// as row number index, which GetPossibleNextRowsForDist leaves out
+func CanCloseAllForDist(dist Dist, index int) bool {
+	return distKey(dist, index).IsZero()
+}
+
+// distKey gets the columns that can't have a black square as row number
+// index, since a run would end too short
+func distKey(dist Dist, index int) Row {
+	var key Row
+	open := distOpen()
+	for i := 0; i < Width; i++ {
 		curDist := GetDistAtIndex(dist, i)
-		if curDist == 1 || curDist == 2 {
-			key = key | (1 << (BoardSize - 1 - i))
+		switch {
+		case curDist == open:
+			// best case, the opposite column stays open all the way up
+			opposite := HalfHeight
+			if oppositeColumn(i) == i {
+				opposite = index
+			}
+			if !isValidLength(middleRunLength(index, opposite)) {
+				key = key.WithBit(i)
+			}
+		case curDist > open:
+			u := int(curDist - open - 1)
+			if !isValidLength(middleRunLength(index, u)) {
+				key = key.WithBit(i)
+			}
+		case curDist != 0 && curDist != uint8(MinLength):
+			key = key.WithBit(i)
 		}
 	}
-	return AvoidOneOneBitarrayMap[key]
+	return key
 }
 
-func ApplyDist(dist [DistSize]uint8, row uint32) [DistSize]uint8 {
-	// this is a lot easier than reach lmao
-	var ret [DistSize]uint8
-	rowArr := RowToArray(row)
-	for i := uint8(0); i < BoardSize; i++ {
-		if rowArr[i] {
-			SetDistAtIndex(&ret, i, 3)
-		} else {
-			curDist := GetDistAtIndex(dist, i)
-			if curDist == 0 {
-				// 000 -> still 000
-				SetDistAtIndex(&ret, i, 0)
-			} else {
-				// 001 -> 010 -> 100 -> 000
-				SetDistAtIndex(&ret, i, curDist-1)
+// ApplyDist adds row on top of dist as row number index, counting out
+// from the middle. It returns false if that finishes off a down run
+// through the middle that's too short.
+func ApplyDist(dist Dist, row Row, index int) (bool, Dist) {
+	ret := make([]uint8, DistSize)
+	open := distOpen()
+	for i := 0; i < Width; i++ {
+		curDist := GetDistAtIndex(dist, i)
+		opposite := oppositeColumn(i)
+		if curDist < open {
+			if row.Bit(i) {
+				SetDistAtIndex(ret, i, uint8(MinLength))
+			} else if curDist > 0 {
+				// count down towards 0
+				SetDistAtIndex(ret, i, curDist-1)
 			}
+			continue
+		}
+
+		if !row.Bit(i) {
+			// still open, but the opposite column might be closing now
+			next := curDist
+			if curDist == open && opposite != i && row.Bit(opposite) {
+				u := index
+				if u > MinLength {
+					u = MinLength
+				}
+				next = open + 1 + uint8(u)
+			}
+			// the shortest the run through the middle can still be
+			shortest := middleRunLength(index+1, index+1)
+			if next > open {
+				shortest = middleRunLength(index+1, int(next-open-1))
+			}
+			if shortest >= MinLength && !wrapsDown() {
+				next = 0
+			}
+			SetDistAtIndex(ret, i, next)
+			continue
+		}
+
+		// this column closes after index squares, so if the opposite
+		// column is closed too we know how long the run through the middle is
+		SetDistAtIndex(ret, i, uint8(MinLength))
+		length := -1
+		switch {
+		case curDist > open:
+			length = middleRunLength(index, int(curDist-open-1))
+		case opposite == i || row.Bit(opposite):
+			length = middleRunLength(index, index)
+		}
+		if length >= 0 && !isValidLength(length) {
+			return false, ""
 		}
 	}
-	return ret
+	return true, Dist(ret)
 }
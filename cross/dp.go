@@ -0,0 +1,1000 @@
+package cross
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+)
+
+const numThreads int = 15
+const logFrequency uint64 = 10000000
+
+// These help with debugging / special cases
+// but they increase the number of states / time / memory
+const includeBoardString = false
+const includeBoardArr = false
+const includeHasEdge = true
+
+// NoCheaters is the Config.MaxCheaters for boards without any cheaters,
+// since 0 means there's no limit
+const NoCheaters = -1
+
+// trackBlacks and trackWords have the DP count boards by how many black
+// squares and words they have too, which makes it slower
+var trackBlacks, trackWords bool
+
+// trackLengths has the DP keep entry length stats too, see LengthStats
+var trackLengths bool
+
+// bounds has the limits on black squares and words, which the DP needs
+// trackBlacks and trackWords for
+var bounds Bounds
+
+// maxCheaters is the most cheaters a board can have, or -1 for no limit
+var maxCheaters int
+
+// Counts is what Count finds out about the boards
+type Counts struct {
+	// Boards has how many boards there are, with the coefficient of
+	// x^k y^j how many have k black squares and j words when Config
+	// asks for those. Otherwise they all go in with 0
+	Boards Poly2
+	// Lengths has the entry length stats when Config.EntryLengths is set
+	Lengths LengthStats
+}
+
+// Total is how many boards there are
+func (c Counts) Total() *big.Int {
+	return c.Boards.Sum()
+}
+
+// Count counts the boards cfg describes, like the completions of a
+// partial board. It calls Init, so the lookup tables are left set up for
+// cfg.
+func Count(cfg Config) (Counts, error) {
+	if err := Init(cfg); err != nil {
+		return Counts{}, err
+	}
+	t := count()
+	return Counts{t.boards, t.lengths}, nil
+}
+
+type State struct {
+	lastRow     Row
+	topReach    Reach
+	bottomReach Reach
+	dist        Dist
+	runs        Runs
+	topRuns     TopRuns
+	boardString string
+	// edges has bit 1 set once the left side of the board has a white
+	// square and bit 2 for the right side
+	edges    uint8
+	isMirror bool
+	// closed is how many components of white squares have been closed
+	// off, so nothing can join up with them any more. With any number of
+	// components allowed it only goes up to 1, to tell if there are any
+	closed uint8
+	// blackReach and blackClosed do the same for the black regions, when
+	// BlackRegions is set
+	blackReach  BlackReach
+	blackClosed uint8
+	// cuts keeps track of where cuts can still turn up, when Biconnected
+	// is set
+	cuts Cuts
+	// prevRow is the row before lastRow, and cheaters is how many
+	// cheaters the rows before lastRow have. Whether lastRow's black
+	// squares are cheaters depends on the rows on both sides of it. These
+	// are only kept when maxCheaters is set
+	prevRow  Row
+	cheaters int
+	// earlier has the rows before lastRow that a forbidden pattern could
+	// still reach, newest first, and firstRows has the top rows of a
+	// torus built from the top, which patterns can wrap around to
+	earlier   RecentRows
+	firstRows RecentRows
+	// motifs is how many times the motif shows up so far, up to MotifCap
+	motifs uint8
+}
+
+// rowBlacks is how many black squares adding row as row number index puts
+// on the board, counting its opposite row too. It's 0 unless trackBlacks
+// is on, so the polynomials stay a single number
+func rowBlacks(row Row, index int) int {
+	if !trackBlacks {
+		return 0
+	}
+	return Copies(index) * row.AndNot(Mask[DPRow(index)]).OnesCount()
+}
+
+// addedWords is how many words adding row as row number index after
+// lastRow starts, counting its opposite row too. It's 0 unless trackWords
+// is on
+func addedWords(lastRow, row Row, index int) int {
+	if !trackWords {
+		return 0
+	}
+	words := Copies(index) * RowEntries(row)
+	switch {
+	case !Folded():
+		words += EntryStarts(lastRow, row)
+	case index > 0:
+		// row is on top of lastRow. The entries that end in row stand in
+		// for the ones that start in its opposite, in the bottom half
+		words += EntryStarts(row, lastRow) + EntryStarts(lastRow, row)
+	case Height%2 == 0:
+		// the middle pair, where the row under row is its opposite
+		words += EntryStarts(Opposite(row), row)
+	}
+	return words
+}
+
+// rowCheaters is how many cheaters row number index has, counting its
+// opposite row too, given the rows on either side of it. It's 0 unless
+// maxCheaters is set
+func rowCheaters(a, row, b Row, index int) int {
+	if maxCheaters < 0 {
+		return 0
+	}
+	return Copies(index) * RowCheaters(a, row, b, Mask[DPRow(index)])
+}
+
+// tooManyCheaters says whether a board with cheaters cheaters is over
+// the limit
+func tooManyCheaters(cheaters int) bool {
+	return maxCheaters >= 0 && cheaters > maxCheaters
+}
+
+// keepRow is row if maxCheaters is set, so states only hang on to the row
+// before lastRow when they need it
+func keepRow(row Row) Row {
+	if maxCheaters < 0 {
+		return Row{}
+	}
+	return row
+}
+
+// closeComponents adds closed, the components a new row just closed off,
+// to the ones state already has. It says whether the board can still end
+// up with the right number of them, given the reaches after the new row
+func closeComponents(state State, closed int, topReach, bottomReach Reach) (uint8, bool) {
+	closed += int(state.closed)
+	if !CanHaveComponents(closed, OpenComponents(topReach, bottomReach) > 0) {
+		return 0, false
+	}
+	if Components == AnyComponents {
+		closed = min(closed, 1)
+	}
+	return uint8(closed), true
+}
+
+// addsPattern says whether adding rows[0] as row number index, on top of
+// the rest of rows, finishes a forbidden pattern
+func addsPattern(index int, rows ...Row) bool {
+	return PatternHeight > 0 && AddsPattern(rows, index)
+}
+
+// addMotifs adds the motifs that adding rows[0] as row number index, on
+// top of the rest of rows, finishes to the ones a board already has, and
+// says whether it can still end up with the right number
+func addMotifs(motifs, index int, rows ...Row) (int, bool) {
+	if !HasMotif() {
+		return 0, true
+	}
+	motifs = min(motifs+AddsMotifs(rows, index), MotifCap())
+	return motifs, CanHaveMotifs(motifs)
+}
+
+// stateRows is row and then the rows state still has, newest first
+func stateRows(row Row, state State) []Row {
+	return append([]Row{row, state.lastRow}, state.earlier.Rows()...)
+}
+
+// keepFirst adds row number index to first if it's one of the top rows a
+// torus built from the top needs for patterns across the edge
+func keepFirst(first RecentRows, row Row, index int) RecentRows {
+	if Folded() || BoardTopology != Torus || index >= PatternHeight-1 {
+		return first
+	}
+	return first.Push(row, PatternHeight-1)
+}
+
+// addedLengths is the lengths of the entries that adding row as row number
+// index on top of runs and topRuns finishes, counting its opposite row
+// too. It's nil unless trackLengths is on
+func addedLengths(runs Runs, topRuns TopRuns, row Row, index int) []int {
+	if !trackLengths {
+		return nil
+	}
+	var lengths []int
+	for c := 0; c < Copies(index); c++ {
+		lengths = RowEntryLengths(lengths, row)
+	}
+	return FinishedRuns(lengths, runs, topRuns, row, index)
+}
+
+// tally is what the DP keeps for each state. The coefficient of x^k y^j
+// in boards is how many boards have k black squares and j words so far,
+// and lengths has their entry lengths if trackLengths is on
+type tally struct {
+	boards  Poly2
+	lengths LengthStats
+}
+
+// add adds the boards in t to to, where each of them gets blacks more
+// black squares, words more words and entries with the given lengths. It
+// changes to in place, like Poly.AddShifted
+func (to tally) add(t tally, blacks, words int, lengths []int) tally {
+	to.boards = to.boards.AddShifted(t.boards, blacks, words)
+	if trackLengths {
+		to.lengths = to.lengths.Add(t.lengths, lengths)
+	}
+	return to
+}
+
+// oneBoard is the tally for a single board with nothing on it yet
+func oneBoard() tally {
+	return tally{Poly2{Monomial(1, 0)}, OneBoard()}
+}
+
+// count runs the DP for whatever size cross was last initialized with
+func count() tally {
+	if BoardStrategy == StrategySearch {
+		return countBySearch()
+	}
+	t, _ := runDP(false)
+	return t
+}
+
+// runDP is count for the symmetries the DP handles. With keep, it keeps
+// every layer of the DP too, see Layers
+func runDP(keep bool) (tally, *Layers) {
+	var mutex sync.Mutex
+	dp := make(map[State]tally)
+	var lay *Layers
+	if keep {
+		lay = &Layers{dp: make([]map[State]tally, HalfHeight)}
+	}
+
+	// addState adds a board with blacks black squares, words words and
+	// entries with the given lengths, which rows got to
+	addState := func(state State, rows []Row, blacks, words int, lengths []int) {
+		dp[state] = dp[state].add(oneBoard(), blacks, words, lengths)
+		if lay != nil {
+			lay.starts = append(lay.starts, start{rows, state, blacks, words})
+		}
+	}
+
+	// boards that wrap around don't have sides to check, and neither do
+	// shapes, see HasBlackBorder
+	shaped := HasShape()
+	startEdges := uint8(0)
+	if !includeHasEdge || BoardTopology != Flat || shaped {
+		startEdges = 3
+	}
+	torus := BoardTopology == Torus
+	// building out from the middle, the rows around the middle can all be
+	// black when the white squares past them can wrap around to each
+	// other, or don't have to connect up
+	splits := Folded() && (torus || Components != 1)
+
+	// The DP either adds rows in pairs moving out from the middle, or one
+	// at a time from the top. firstIndex is the first row (counting out
+	// from the middle or down from the top) that the DP loop adds
+	firstIndex := 2
+	if Folded() && Height%2 == 1 {
+		// Initialize the DP with the middle row and the row adjacent to it
+		for _, middleRow := range PossMiddleRows {
+			// we only store one of each board and its left-right mirror,
+			// see isMirror
+			if MirrorPairs && Reverse(middleRow).Less(middleRow) || !FitsMask(middleRow, DPRow(0)) || !FitsPartial(middleRow, DPRow(0)) {
+				continue
+			}
+			middleIsMirror := MirrorPairs && middleRow == Reverse(middleRow)
+			possFromMiddleRowList := PossFromMiddle(middleRow)
+			middleReach := RowToReach(middleRow)
+			if splits || shaped {
+				// the middle row can be cut off from the rest of the white
+				// squares, or be the only one with any. A shape can have
+				// all its white squares in the middle row, since it
+				// doesn't have sides that need white squares
+				possFromMiddleRowList = append(possFromMiddleRowList[:len(possFromMiddleRowList):len(possFromMiddleRowList)], MaxRow)
+			}
+			for _, possFromMiddleRow := range possFromMiddleRowList {
+				if middleIsMirror && Reverse(possFromMiddleRow).Less(possFromMiddleRow) {
+					continue
+				}
+				if !FitsMask(possFromMiddleRow, DPRow(1)) || !FitsPartial(possFromMiddleRow, DPRow(1)) {
+					continue
+				}
+
+				// the middle row is row 0 counting out from the middle
+				_, middleDist := ApplyDist(InitDist(), middleRow, 0)
+				ok, initialDist := ApplyDist(middleDist, possFromMiddleRow, 1)
+				if !ok {
+					continue
+				}
+				if possFromMiddleRow == MaxRow && !IsValidDist(middleDist) {
+					continue
+				}
+				ok, middleRuns := ApplyRuns(InitRuns(), middleRow, 0)
+				if !ok {
+					continue
+				}
+				ok, initialRuns := ApplyRuns(middleRuns, possFromMiddleRow, 1)
+				if !ok {
+					continue
+				}
+				closed, initialTopReach, initialBottomReach :=
+					ApplyReach(
+						middleReach,
+						middleReach,
+						middleRow,
+						possFromMiddleRow,
+					)
+				initialClosed, ok := closeComponents(State{}, closed, initialTopReach, initialBottomReach)
+				if !ok {
+					continue
+				}
+				blackClosed, blackReach := InitBlackMiddle(middleRow, possFromMiddleRow)
+				if !CanHaveBlackRegions(blackClosed) {
+					continue
+				}
+				cuts, ok := InitMiddleCuts(middleRow, possFromMiddleRow)
+				if !ok {
+					continue
+				}
+
+				boardString := ""
+				if includeBoardString {
+					boardString =
+						fmt.Sprintf(
+							"%v%v%v",
+							RowToString(possFromMiddleRow),
+							RowToString(middleRow),
+							RowToString(Opposite(possFromMiddleRow)),
+						)
+				}
+
+				// the middle row is between possFromMiddleRow and its
+				// opposite
+				cheaters := rowCheaters(possFromMiddleRow, middleRow, Opposite(possFromMiddleRow), 0)
+				if tooManyCheaters(cheaters) {
+					continue
+				}
+				if addsPattern(0, middleRow) || addsPattern(1, possFromMiddleRow, middleRow) {
+					continue
+				}
+				motifs, _ := addMotifs(0, 0, middleRow)
+				motifs, ok = addMotifs(motifs, 1, possFromMiddleRow, middleRow)
+				if !ok {
+					continue
+				}
+
+				state := State{
+					possFromMiddleRow,
+					initialTopReach,
+					initialBottomReach,
+					initialDist,
+					initialRuns,
+					"",
+					boardString,
+					startEdges | RowEdges(middleRow) | RowEdges(possFromMiddleRow),
+					middleIsMirror && possFromMiddleRow == Reverse(possFromMiddleRow),
+					initialClosed,
+					blackReach,
+					uint8(blackClosed),
+					cuts,
+					keepRow(middleRow),
+					cheaters,
+					RecentRows("").Push(middleRow, PatternHeight-2),
+					"",
+					uint8(motifs),
+				}
+				addState(state, []Row{middleRow, possFromMiddleRow},
+					rowBlacks(middleRow, 0)+rowBlacks(possFromMiddleRow, 1),
+					addedWords(MaxRow, middleRow, 0)+addedWords(middleRow, possFromMiddleRow, 1),
+					append(
+						addedLengths(InitRuns(), "", middleRow, 0),
+						addedLengths(middleRuns, "", possFromMiddleRow, 1)...,
+					),
+				)
+			}
+		}
+	} else {
+		// There's no middle row, so initialize the DP with the middle pair
+		// of rows, which are each other's opposites. Or with the top row if
+		// the board gets built from the top
+		firstIndex = 1
+		firstRows := AllRows
+		if (torus || shaped) && !Folded() {
+			// all-black rows can go anywhere on a torus built from the
+			// top, since the rows under them can still wrap around, and
+			// a shape doesn't have a top side to keep white
+			firstRows = append(AllRows[:len(AllRows):len(AllRows)], MaxRow)
+		}
+		// the top edge works like a black square, except on a torus, where
+		// TorusTopStarts counts the top row's words at the end
+		aboveFirst := MaxRow
+		if torus {
+			aboveFirst = Row{}
+		}
+		for _, firstRow := range firstRows {
+			if MirrorPairs && Reverse(firstRow).Less(firstRow) || !FitsMask(firstRow, DPRow(0)) || !FitsPartial(firstRow, DPRow(0)) {
+				continue
+			}
+			if addsPattern(0, firstRow) {
+				continue
+			}
+			motifs, ok := addMotifs(0, 0, firstRow)
+			if !ok {
+				continue
+			}
+
+			// runs through the middle pair carry on into the bottom half,
+			// which the dist keeps track of
+			_, initialDist := ApplyDist(InitDist(), firstRow, 0)
+			ok, initialRuns := ApplyRuns(InitRuns(), firstRow, 0)
+			if !ok {
+				continue
+			}
+			var initialTopReach, initialBottomReach Reach
+			if Folded() {
+				initialTopReach, initialBottomReach = InitPairReach(firstRow)
+			} else {
+				initialTopReach = RowToReach(firstRow)
+				if torus {
+					// the bottom reach keeps track of the top row, which
+					// the last row wraps around to
+					initialBottomReach = initialTopReach
+				}
+			}
+
+			boardString := ""
+			if includeBoardString {
+				boardString = RowToString(firstRow)
+				if Folded() {
+					boardString += RowToString(Opposite(firstRow))
+				}
+			}
+
+			// the middle pair are on either side of each other, and the
+			// top row is under the edge
+			prevRow := MaxRow
+			if Folded() {
+				prevRow = Opposite(firstRow)
+			}
+
+			addState(State{
+				firstRow,
+				initialTopReach,
+				initialBottomReach,
+				initialDist,
+				initialRuns,
+				ApplyTopRuns(InitTopRuns(), firstRow, 0),
+				boardString,
+				startEdges | RowEdges(firstRow),
+				MirrorPairs && firstRow == Reverse(firstRow),
+				0,
+				InitBlackReach(firstRow),
+				0,
+				InitCuts(firstRow),
+				keepRow(prevRow),
+				0,
+				"",
+				keepFirst("", firstRow, 0),
+				uint8(motifs),
+			}, []Row{firstRow}, rowBlacks(firstRow, 0), addedWords(aboveFirst, firstRow, 0),
+				addedLengths(InitRuns(), InitTopRuns(), firstRow, 0))
+		}
+	}
+
+	// When the board is built out from the middle and splits, there can
+	// be black rows all the way out from the middle, with the rest of the
+	// board wrapping around from the top to the bottom on a torus, or
+	// in separate pieces above and below them. addBandStates adds the
+	// states where row number index is the first that isn't all black
+	addBandStates := func(index int) {
+		dist, runs := InitDist(), InitRuns()
+		bandBlacks, bandWords, bandCheaters, bandMotifs := 0, 0, 0, 0
+		var bandLengths []int
+		// band has the all-black rows, newest first
+		var band []Row
+		var earlier RecentRows
+		for i := 0; i < index; i++ {
+			band = append([]Row{MaxRow}, band...)
+			if !FitsPartial(MaxRow, DPRow(i)) || addsPattern(i, band...) {
+				return
+			}
+			var ok bool
+			bandMotifs, ok = addMotifs(bandMotifs, i, band...)
+			if !ok {
+				return
+			}
+			earlier = earlier.Push(MaxRow, PatternHeight-2)
+			bandLengths = append(bandLengths, addedLengths(runs, "", MaxRow, i)...)
+			_, dist = ApplyDist(dist, MaxRow, i)
+			_, runs = ApplyRuns(runs, MaxRow, i)
+			bandBlacks += rowBlacks(MaxRow, i)
+			bandWords += addedWords(MaxRow, MaxRow, i)
+			if i < index-1 {
+				bandCheaters += rowCheaters(MaxRow, MaxRow, MaxRow, i)
+			}
+		}
+		for _, row := range AllRows {
+			if MirrorPairs && Reverse(row).Less(row) || !FitsMask(row, DPRow(index)) || !FitsPartial(row, DPRow(index)) {
+				continue
+			}
+			if addsPattern(index, append([]Row{row}, band...)...) {
+				continue
+			}
+			motifs, ok := addMotifs(bandMotifs, index, append([]Row{row}, band...)...)
+			if !ok {
+				continue
+			}
+			_, nextDist := ApplyDist(dist, row, index)
+			ok, nextRuns := ApplyRuns(runs, row, index)
+			if !ok {
+				continue
+			}
+			// the last black row is between row and another black row,
+			// or row's opposite if it's the middle row
+			below := MaxRow
+			if Height%2 == 1 && index == 1 {
+				below = Opposite(row)
+			}
+			cheaters := bandCheaters + rowCheaters(below, MaxRow, row, index-1)
+			if tooManyCheaters(cheaters) {
+				continue
+			}
+			blackClosed, blackReach := InitBlackBand(row, index)
+			if !CanHaveBlackRegions(blackClosed) {
+				continue
+			}
+			cuts, ok := InitBandCuts(row, index)
+			if !ok {
+				continue
+			}
+			topReach, bottomReach := InitSplitReach(row)
+			boardString := ""
+			if includeBoardString {
+				boardString = RowToString(row) + RowToString(Opposite(row))
+			}
+			// the band is all black, so it's in order either way round
+			addState(State{
+				row,
+				topReach,
+				bottomReach,
+				nextDist,
+				nextRuns,
+				"",
+				boardString,
+				startEdges | RowEdges(row),
+				MirrorPairs && row == Reverse(row),
+				0,
+				blackReach,
+				uint8(blackClosed),
+				cuts,
+				keepRow(MaxRow),
+				cheaters,
+				earlier,
+				"",
+				uint8(motifs),
+			}, append(band[:len(band):len(band)], row), bandBlacks+rowBlacks(row, index), bandWords+addedWords(MaxRow, row, index),
+				append(bandLengths[:len(bandLengths):len(bandLengths)], addedLengths(runs, "", row, index)...))
+		}
+	}
+	if splits {
+		for index := 1; index < firstIndex; index++ {
+			addBandStates(index)
+		}
+	}
+
+	// mostBlacks[i] and mostWords[i] are the most black squares and words
+	// that row number i and the rest can add to a board. The words that
+	// start in the top row get counted at the very end, so that's another
+	// Width at most
+	mostBlacks := make([]int, HalfHeight+1)
+	mostWords := make([]int, HalfHeight+1)
+	mostWords[HalfHeight] = Width
+	for i := HalfHeight - 1; i >= 0; i-- {
+		mostBlacks[i] = mostBlacks[i+1] + Copies(i)*MaxRow.AndNot(Mask[DPRow(i)]).OnesCount()
+		mostWords[i] = mostWords[i+1] + Copies(i)*MostEntries(Width) + Width
+	}
+	// prune drops the boards that can't end up in bounds any more, once
+	// the rows up to number index are in, and any states left without any
+	prune := func(index int) {
+		if !bounds.Blacks() && !bounds.Words() {
+			return
+		}
+		minBlacks, maxBlacks := bounds.BlackRange(mostBlacks[index+1])
+		minWords, maxWords := bounds.WordRange(mostWords[index+1])
+		for state, t := range dp {
+			t.boards = t.boards.Clip(minBlacks, maxBlacks, minWords, maxWords)
+			if t.boards.IsZero() {
+				delete(dp, state)
+			} else {
+				dp[state] = t
+			}
+		}
+	}
+	prune(firstIndex - 1)
+
+	getPossibleNextRows := func(state State, index int) []Row {
+		// After an all-black row closes off the last component the
+		// board can have, we can only add more all-black rows
+		if state.lastRow == MaxRow && !CanHaveComponents(int(state.closed), true) {
+			return []Row{MaxRow}
+		} else {
+			possRows := GetPossibleNextRowsForDist(state.dist, index).
+				And(GetPossibleNextRowsForRuns(state.runs, index)).
+				And(AvoidOneZero(Mask[DPRow(index)]))
+			// a white square this close to the edge can't start a long
+			// enough run
+			if index > HalfHeight-MinLength && !torus {
+				possRows = possRows.And(AvoidOneZero(state.lastRow))
+			}
+			ret := ToRows(possRows)
+
+			// If we're at a valid stopping state, we can start adding
+			// all-black rows. From the top that needs room for another
+			// component after it, since the bottom row can't be all black
+			// unless there's a shape
+			open := int(state.closed) + OpenComponents(state.topReach, state.bottomReach)
+			if Folded() && CanHaveComponents(open, false) && IsValidDist(state.dist) {
+				ret = append(ret, MaxRow)
+			} else if !Folded() && torus && CanCloseAllForDist(state.dist, index) {
+				ret = append(ret, MaxRow)
+			} else if !Folded() && !torus && CanHaveComponents(open, !shaped) && CanCloseAllForDist(state.dist, index) {
+				ret = append(ret, MaxRow)
+			}
+			return ret
+		}
+	}
+
+	// next calls f for each row that can come after state as row number
+	// index, with the state that makes, the black squares and words it
+	// adds and the lengths of the entries it finishes
+	next := func(state State, curIndex int, f func(nextRow Row, nextState State, blacks, words int, lengths []int)) {
+		// iterate over all possible next rows
+		for _, nextRow := range getPossibleNextRows(state, curIndex) {
+			// Once we're done with the mirror-symmetric part, we only need to
+			// store one copy of each mirror-symmetric board. So only take
+			// one of every row, Reverse(row) pair
+			if state.isMirror && Reverse(nextRow).Less(nextRow) {
+				continue
+			}
+			// and only the rows that agree with the squares that
+			// are filled in already
+			if !FitsPartial(nextRow, DPRow(curIndex)) {
+				continue
+			}
+			// lastRow is between prevRow and nextRow now
+			cheaters := state.cheaters + rowCheaters(state.prevRow, state.lastRow, nextRow, curIndex-1)
+			if tooManyCheaters(cheaters) {
+				continue
+			}
+			if addsPattern(curIndex, stateRows(nextRow, state)...) {
+				continue
+			}
+			motifs, ok := addMotifs(int(state.motifs), curIndex, stateRows(nextRow, state)...)
+			if !ok {
+				continue
+			}
+
+			var closed int
+			var nextTopReach, nextBottomReach Reach
+			if Folded() {
+				closed, nextTopReach, nextBottomReach =
+					ApplyReach(
+						state.topReach,
+						state.bottomReach,
+						state.lastRow,
+						nextRow,
+					)
+			} else if torus {
+				closed, nextTopReach, nextBottomReach =
+					ApplyTorusReach(
+						state.topReach,
+						state.bottomReach,
+						state.lastRow,
+						nextRow,
+					)
+			} else {
+				closed, nextTopReach = ApplySingleReach(state.topReach, state.lastRow, nextRow)
+			}
+			nextClosed, ok := closeComponents(state, closed, nextTopReach, nextBottomReach)
+			if !ok {
+				continue
+			}
+			blackClosed, blackReach := ApplyBlackReach(state.blackReach, nextRow, curIndex)
+			blackClosed += int(state.blackClosed)
+			if !CanHaveBlackRegions(blackClosed) {
+				continue
+			}
+			cuts, ok := ApplyCuts(state.cuts, nextRow)
+			if !ok {
+				continue
+			}
+
+			ok, nextDist := ApplyDist(state.dist, nextRow, curIndex)
+			if !ok {
+				continue
+			}
+			ok, nextRuns := ApplyRuns(state.runs, nextRow, curIndex)
+			if !ok {
+				continue
+			}
+			boardString := ""
+			if includeBoardString {
+				boardString =
+					fmt.Sprintf(
+						"%v   %v\n%v\n%v   %v",
+						ReachToString(nextTopReach),
+						RowToString(nextRow),
+						state.boardString,
+						ReachToString(nextBottomReach),
+						RowToString(Opposite(nextRow)),
+					)
+			}
+
+			nextState := State{
+				nextRow,
+				nextTopReach,
+				nextBottomReach,
+				nextDist,
+				nextRuns,
+				ApplyTopRuns(state.topRuns, nextRow, curIndex),
+				boardString,
+				state.edges | RowEdges(nextRow),
+				state.isMirror && nextRow == Reverse(nextRow),
+				nextClosed,
+				blackReach,
+				uint8(blackClosed),
+				cuts,
+				keepRow(state.lastRow),
+				cheaters,
+				state.earlier.Push(state.lastRow, PatternHeight-2),
+				keepFirst(state.firstRows, nextRow, curIndex),
+				uint8(motifs),
+			}
+			f(nextRow, nextState,
+				rowBlacks(nextRow, curIndex),
+				addedWords(state.lastRow, nextRow, curIndex),
+				addedLengths(state.runs, state.topRuns, nextRow, curIndex),
+			)
+		}
+	}
+
+	if lay != nil {
+		lay.dp[firstIndex-1] = dp
+	}
+	fmt.Fprintln(os.Stderr, "Starting DP...")
+	// Do the actual DP, parallelize some stuff too
+	for curIndex := firstIndex; curIndex < HalfHeight; curIndex++ {
+		newDp := make(map[State]tally)
+		var keyList []State
+		for key, _ := range dp {
+			keyList = append(keyList, key)
+		}
+
+		run := func(wg *sync.WaitGroup, thread_id int) {
+			defer wg.Done()
+			for ind, state := range keyList {
+				if ind%numThreads != thread_id {
+					continue
+				}
+				next(state, curIndex, func(nextRow Row, nextState State, blacks, words int, lengths []int) {
+					mutex.Lock()
+					newDp[nextState] = newDp[nextState].add(dp[state], blacks, words, lengths)
+					mutex.Unlock()
+				})
+			}
+		}
+		var wg sync.WaitGroup
+		wg.Add(numThreads)
+		for thread_id := 0; thread_id < numThreads; thread_id++ {
+			go run(&wg, thread_id)
+		}
+		wg.Wait()
+		dp = newDp
+		if splits {
+			addBandStates(curIndex)
+		}
+		prune(curIndex)
+		if lay != nil {
+			lay.dp[curIndex] = dp
+		}
+		fmt.Fprintf(os.Stderr, "Done with round %v, DP has %v states.\n", curIndex, len(dp))
+	}
+
+	// end says whether a state after the last row is a whole board, and
+	// how many words and which entry lengths it still needs to count
+	end := func(state State) (bool, int, []int) {
+		if !HasBlackRegions(EndBlackRegions(state.blackReach, int(state.blackClosed))) || !EndCuts(state.cuts) {
+			return false, 0, nil
+		}
+		switch {
+		case !torus:
+			if !HasComponents(int(state.closed) + OpenComponents(state.topReach, state.bottomReach)) {
+				return false, 0, nil
+			}
+			// the pruning near the edge doesn't see the first few rows on
+			// short boards, so make sure no column ends in a short run
+			if !IsValidDist(state.dist) {
+				return false, 0, nil
+			}
+			// all-black rows at the end are a black side, unless there's
+			// a shape
+			if state.edges != 3 || state.lastRow == MaxRow && !shaped {
+				return false, 0, nil
+			}
+			// the last row is under the edge, or the top row when
+			// building out from the middle
+			if tooManyCheaters(state.cheaters + rowCheaters(state.prevRow, state.lastRow, MaxRow, HalfHeight-1)) {
+				return false, 0, nil
+			}
+		default:
+			// the top and bottom rows touch, so runs and components can
+			// carry on across them
+			if !HasComponents(int(state.closed) + TorusComponents(state.topReach, state.bottomReach)) {
+				return false, 0, nil
+			}
+			if !IsValidTorus(state.dist, state.runs, state.topRuns) {
+				return false, 0, nil
+			}
+		}
+		// patterns can go across the edge of a torus too
+		if torus && PatternHeight > 0 && EndsInPattern(append([]Row{state.lastRow}, state.earlier.Rows()...), state.firstRows.Rows()) {
+			return false, 0, nil
+		}
+		motifs := int(state.motifs)
+		if torus && HasMotif() {
+			motifs += EndsInMotifs(append([]Row{state.lastRow}, state.earlier.Rows()...), state.firstRows.Rows())
+		}
+		if !HasMotifs(min(motifs, MotifCap())) {
+			return false, 0, nil
+		}
+		// the words that start in the top row and the entries that run
+		// off the edge, which the DP hasn't counted yet
+		words := 0
+		switch {
+		case !trackWords:
+		case torus:
+			words = TorusTopStarts(state.lastRow, state.dist, state.topRuns)
+		case Folded():
+			words = EntryStarts(MaxRow, state.lastRow)
+		}
+		var lengths []int
+		if trackLengths {
+			lengths = EndRuns(nil, state.runs, state.topRuns)
+		}
+		return true, words, lengths
+	}
+
+	var ans tally
+	for state, count := range dp {
+		ok, words, lengths := end(state)
+		if !ok {
+			continue
+		}
+		// a board that isn't its own mirror stands in for its mirror
+		// too, unless the shape means the mirror isn't a board
+		if state.isMirror || !MirrorPairs {
+			ans = ans.add(count, 0, words, lengths)
+		} else {
+			ans = ans.add(count, 0, words, lengths)
+			ans = ans.add(count, 0, words, lengths)
+		}
+		if includeBoardString {
+			fmt.Printf("board %v\n\n", state.boardString)
+		}
+	}
+	minBlacks, maxBlacks := bounds.BlackRange(0)
+	minWords, maxWords := bounds.WordRange(0)
+	ans.boards = ans.boards.Clip(minBlacks, maxBlacks, minWords, maxWords)
+	if lay != nil {
+		lay.next, lay.end = next, end
+	}
+	return ans, lay
+}
+
+// searchedBoard checks the rules SearchBoards doesn't on one of the
+// boards it finds, and gets how many black squares and words it has if
+// trackBlacks and trackWords are on
+func searchedBoard(board []Row) (bool, int, int) {
+	if HasBlackBorder(board) {
+		return false, 0, 0
+	}
+	blacks := 0
+	if trackBlacks {
+		for r := 0; r < Height; r++ {
+			blacks += board[r].AndNot(Mask[r]).OnesCount()
+		}
+	}
+	words := 0
+	if trackWords {
+		words = BoardEntries(board)
+	}
+	if !bounds.CanReach(blacks, words, 0, 0) {
+		return false, 0, 0
+	}
+	if maxCheaters >= 0 && tooManyCheaters(len(Cheaters(board))) {
+		return false, 0, 0
+	}
+	if PatternHeight > 0 && HasForbidden(board) {
+		return false, 0, 0
+	}
+	return HasMotifs(BoardMotifs(board)), blacks, words
+}
+
+// searchLayers is runDP for the symmetries the DP can't handle. Every
+// board is a start of its own in the one layer there is, told apart from
+// the others by its boardString
+func searchLayers() *Layers {
+	last := HalfHeight - 1
+	lay := &Layers{
+		dp: make([]map[State]tally, HalfHeight),
+		end: func(State) (bool, int, []int) {
+			return true, 0, nil
+		},
+	}
+	lay.dp[last] = make(map[State]tally)
+	SearchBoards(0, 1, func(board []Row) {
+		ok, blacks, words := searchedBoard(board)
+		if !ok {
+			return
+		}
+		state := State{lastRow: board[last], boardString: SliceBoardToString(board)}
+		lay.starts = append(lay.starts, start{append([]Row(nil), board...), state, blacks, words})
+		lay.dp[last][state] = lay.dp[last][state].add(oneBoard(), blacks, words, nil)
+	})
+	return lay
+}
+
+// countBySearch counts boards one at a time for symmetries the DP can't
+// handle
+func countBySearch() tally {
+	var total tally
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(numThreads)
+	for thread_id := 0; thread_id < numThreads; thread_id++ {
+		go func(thread_id int) {
+			defer wg.Done()
+			// counts[j][k] is how many boards this thread found with j
+			// words and k black squares
+			var counts [][]uint64
+			var lengths LengthStats
+			SearchBoards(thread_id, numThreads, func(board []Row) {
+				ok, blacks, words := searchedBoard(board)
+				if !ok {
+					return
+				}
+				for len(counts) <= words {
+					counts = append(counts, nil)
+				}
+				for len(counts[words]) <= blacks {
+					counts[words] = append(counts[words], 0)
+				}
+				counts[words][blacks]++
+				if trackLengths {
+					lengths = lengths.Add(OneBoard(), BoardEntryLengths(nil, board))
+				}
+			})
+			mutex.Lock()
+			for j, row := range counts {
+				for k, c := range row {
+					total.boards = total.boards.AddShifted(Poly2{Poly{new(big.Int).SetUint64(c)}}, k, j)
+				}
+			}
+			if trackLengths {
+				total.lengths = total.lengths.Add(lengths, nil)
+			}
+			mutex.Unlock()
+		}(thread_id)
+	}
+	wg.Wait()
+	return total
+}
@@ -5,33 +5,58 @@ import (
 	"strings"
 )
 
-func RowToArray(row uint32) [BoardSize]bool {
-	var ret [BoardSize]bool
-	for i := uint32(0); i < BoardSize; i++ {
-		ret[BoardSize-1-i] = (row & (1 << i)) > 0
+func RowToArray(row Row) []bool {
+	ret := make([]bool, Width)
+	for i := 0; i < Width; i++ {
+		ret[i] = row.Bit(i)
 	}
 	return ret
 }
 
-func ArrayToRow(arr [BoardSize]bool) uint32 {
-	var row uint32 = 0
-	for i := uint32(0); i < BoardSize; i++ {
+func ArrayToRow(arr []bool) Row {
+	var row Row
+	for i := 0; i < Width; i++ {
 		if arr[i] {
-			row = row | (1 << (BoardSize - i - 1))
+			row = row.WithBit(i)
 		}
 	}
 	return row
 }
 
-func RowHasEdge(row uint32) bool {
-	return ((row & (1 << 0)) == 0) || ((row & (1 << (BoardSize - 1))) == 0)
+// RowEdges says which sides of the board row has white squares on, bit 1
+// for the left and 2 for the right. That includes Opposite(row) if the
+// board gets built out from the middle, so once every row has been added
+// a board with no black edge down either side has both bits set.
+func RowEdges(row Row) uint8 {
+	rows := []Row{row}
+	if Folded() {
+		rows = append(rows, Opposite(row))
+	}
+	edges := uint8(0)
+	for _, r := range rows {
+		if !r.Bit(0) {
+			edges |= 1
+		}
+		if !r.Bit(Width - 1) {
+			edges |= 2
+		}
+	}
+	return edges
 }
 
-func RowToString(row uint32) string {
-	return fmt.Sprintf("%0*b", BoardSize, row)
+func RowToString(row Row) string {
+	var b strings.Builder
+	for i := 0; i < Width; i++ {
+		if row.Bit(i) {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	return b.String()
 }
 
-func SliceBoardToString(board []uint32) string {
+func SliceBoardToString(board []Row) string {
 	var b strings.Builder
 	for _, row := range board {
 		fmt.Fprintf(&b, "%v\n", RowToString(row))
@@ -39,34 +64,184 @@ func SliceBoardToString(board []uint32) string {
 	return b.String()
 }
 
-func BoardToString(board [HalfBoardSize]uint32) string {
+// BoardToGrid unfolds the top HalfHeight rows of a board into the
+// full Height x Width grid, true being black
+func BoardToGrid(board []Row) [][]bool {
+	grid := make([][]bool, Height)
+	for i := 0; i < HalfHeight; i++ {
+		grid[i] = RowToArray(board[i])
+		if Folded() {
+			grid[Height-1-i] = RowToArray(Opposite(board[i]))
+		}
+	}
+	return grid
+}
+
+// HasBlackBorder checks if any whole side of the board is black, where
+// sides that wrap around don't count. A shape doesn't have sides, so
+// boards with one never do
+func HasBlackBorder(board []Row) bool {
+	if HasShape() {
+		return false
+	}
+	grid := BoardToGrid(board)
+	allBlack := func(cell func(k int) bool, length int) bool {
+		for k := 0; k < length; k++ {
+			if !cell(k) {
+				return false
+			}
+		}
+		return true
+	}
+	if !wrapsDown() && (allBlack(func(k int) bool { return grid[0][k] }, Width) ||
+		allBlack(func(k int) bool { return grid[Height-1][k] }, Width)) {
+		return true
+	}
+	return !wrapsAcross() && (allBlack(func(k int) bool { return grid[k][0] }, Height) ||
+		allBlack(func(k int) bool { return grid[k][Width-1] }, Height))
+}
+
+// BoardToString prints the board the way it was asked for in Init, so
+// it undoes any transposing. Squares outside the shape are dots
+func BoardToString(board []Row) string {
+	grid := BoardToGrid(board)
 	var b strings.Builder
-	for i := 0; i < HalfBoardSize; i++ {
-		fmt.Fprintf(&b, "%0*b\n", BoardSize, board[i])
+	rows, cols := Height, Width
+	if Transposed {
+		rows, cols = cols, rows
 	}
-	for i := HalfBoardSize - 2; i >= 0; i-- {
-		fmt.Fprintf(&b, "%0*b\n", BoardSize, RevMap[board[i]])
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			r, c := i, j
+			if Transposed {
+				r, c = j, i
+			}
+			if Mask[r].Bit(c) {
+				b.WriteByte('.')
+			} else if grid[r][c] {
+				b.WriteByte('1')
+			} else {
+				b.WriteByte('0')
+			}
+		}
+		b.WriteByte('\n')
 	}
 	return b.String()
 }
 
-const IsConnectedSize = BoardSize
+// BoardToPattern gets the board back the way it was asked for in Init,
+// like BoardToString, with # and . like a partial board has them and
+// spaces for the squares outside the shape
+func BoardToPattern(board []Row) Pattern {
+	grid := BoardToGrid(board)
+	rows, cols := Height, Width
+	if Transposed {
+		rows, cols = cols, rows
+	}
+	ret := make(Pattern, rows)
+	for i := range ret {
+		line := make([]byte, cols)
+		for j := range line {
+			r, c := i, j
+			if Transposed {
+				r, c = j, i
+			}
+			switch {
+			case Mask[r].Bit(c):
+				line[j] = ' '
+			case grid[r][c]:
+				line[j] = '#'
+			default:
+				line[j] = '.'
+			}
+		}
+		ret[i] = string(line)
+	}
+	return ret
+}
 
-var visited [IsConnectedSize][BoardSize]bool
-var grid [IsConnectedSize][BoardSize]bool
+// PatternToBoard reads a board written the way BoardToPattern writes it
+// into every row of the board the way it's stored. The squares outside
+// the shape can be anything.
+func PatternToBoard(p Pattern) ([]Row, error) {
+	rows, cols := Height, Width
+	if Transposed {
+		rows, cols = cols, rows
+	}
+	if len(p) != rows || len(p[0]) != cols {
+		return nil, fmt.Errorf("board is %vx%v but it should be %vx%v", len(p[0]), len(p), cols, rows)
+	}
+	if Transposed {
+		p = p.Transform(Diagonal)
+	}
+	board := make([]Row, Height)
+	for i := range board {
+		for j := 0; j < Width; j++ {
+			switch {
+			case Mask[i].Bit(j) || p[i][j] == '#':
+				board[i] = board[i].WithBit(j)
+			case p[i][j] != '.':
+				if Transposed {
+					i, j = j, i
+				}
+				return nil, fmt.Errorf("the square in row %v column %v has to be # or .", i+1, j+1)
+			}
+		}
+	}
+	return board, nil
+}
 
-func isConnected(board [IsConnectedSize]uint32) bool {
-	for i := 0; i < IsConnectedSize; i++ {
-		grid[i] = RowToArray(board[i])
+// ParseBoard reads a board with every square filled in the way
+// BoardToPattern has them, skipping blank lines. If there's a line that
+// ends in a colon, like "board 5:" or "sample 1:", it reads the board
+// after the first one, up to the next, so it can read a board that
+// count.go printed along with everything else. Lines can leave off the
+// spaces at the end.
+func ParseBoard(s string) (Pattern, error) {
+	lines := strings.Split(s, "\n")
+	for k, line := range lines {
+		if strings.HasSuffix(strings.TrimRight(line, " \t\r"), ":") {
+			lines = lines[k+1:]
+			break
+		}
 	}
-	for i := 0; i < IsConnectedSize; i++ {
-		for j := 0; j < BoardSize; j++ {
-			visited[i][j] = false
+	var ret Pattern
+	width := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.HasSuffix(line, ":") {
+			break
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
 		}
+		if strings.Trim(line, "#. ") != "" {
+			return nil, fmt.Errorf("boards can only have #, . and spaces, got %q", line)
+		}
+		ret = append(ret, line)
+		width = max(width, len(line))
+	}
+	if ret == nil {
+		return nil, fmt.Errorf("there's no board")
+	}
+	for i := range ret {
+		ret[i] += strings.Repeat(" ", width-len(ret[i]))
+	}
+	return ret, nil
+}
+
+// WhiteComponents is how many separate pieces the white squares of a
+// board are in
+func WhiteComponents(board []Row) int {
+	grid := BoardToGrid(board)
+	visited := make([][]bool, Height)
+	for i := 0; i < Height; i++ {
+		visited[i] = make([]bool, Width)
 	}
 	var dfs func(i, j int)
 	dfs = func(i, j int) {
-		if i < 0 || j < 0 || i >= IsConnectedSize || j >= BoardSize {
+		i, j = wrapSquare(i, j)
+		if i < 0 || j < 0 || i >= Height || j >= Width {
 			return
 		}
 		// it is black, do not consider
@@ -82,18 +257,78 @@ func isConnected(board [IsConnectedSize]uint32) bool {
 		dfs(i+1, j)
 		dfs(i-1, j)
 	}
-	// dfs only from first row
-	done := false
-	for i := 0; i < BoardSize; i++ {
-		for j := 0; j < BoardSize; j++ {
-			if !grid[0][j] && !visited[0][j] {
-				if done {
-					return false
-				}
+	components := 0
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			if !grid[i][j] && !visited[i][j] {
 				dfs(i, j)
-				done = true
+				components += 1
 			}
 		}
 	}
-	return true
+	return components
+}
+
+// HasValidRuns checks that every across and down run of white squares in
+// the unfolded board is at least MinLength long, and at most MaxLength
+// if there is one
+func HasValidRuns(board []Row) bool {
+	valid := true
+	forEachBoardRun(board, func(run int) {
+		if run < MinLength || MaxLength != 0 && run > MaxLength {
+			valid = false
+		}
+	})
+	return valid
+}
+
+// forEachBoardRun calls f with the length of every across and down run
+// of white squares in the unfolded board
+func forEachBoardRun(board []Row, f func(run int)) {
+	grid := BoardToGrid(board)
+	for i := 0; i < Height; i++ {
+		forEachRun(func(k int) bool { return grid[i][k] }, Width, wrapsAcross(), f)
+	}
+	for j := 0; j < Width; j++ {
+		forEachRun(func(k int) bool { return grid[k][j] }, Height, wrapsDown(), f)
+	}
+}
+
+// forEachRun calls f with the length of every run of white squares in a
+// line of length squares, where cell(k) says whether square k is black
+func forEachRun(cell func(k int) bool, length int, wraps bool, f func(run int)) {
+	if wraps {
+		// start from a black square so no run goes past the end
+		start := 0
+		for start < length && !cell(start) {
+			start += 1
+		}
+		if start < length {
+			shifted := cell
+			cell = func(k int) bool { return shifted((start + k) % length) }
+		}
+	}
+	run := 0
+	for k := 0; k <= length; k++ {
+		if k < length && !cell(k) {
+			run += 1
+			continue
+		}
+		if run > 0 {
+			f(run)
+		}
+		run = 0
+	}
+}
+
+// wrapSquare moves square (i, j) back onto the board across any edges
+// that wrap around, leaving it off the board otherwise
+func wrapSquare(i, j int) (int, int) {
+	if wrapsAcross() {
+		j = (j + Width) % Width
+	}
+	if wrapsDown() {
+		i = (i + Height) % Height
+	}
+	return i, j
 }
@@ -1,133 +1,405 @@
 package cross
 
 import (
+	"fmt"
 	"github.com/golang-collections/go-datastructures/bitarray"
+	"sync"
 )
 
-var AllRows []uint32
-var PossMiddleRows []uint32
-var MiddleRowMap map[uint32]bool
-var RevMap map[uint32]uint32
-var RowToReachMap map[uint32][HalfBoardSize]uint8
-var PossFromMiddleMap = make(map[uint32]bitarray.BitArray)
-var AvoidOneOneBitarrayMap = make([]bitarray.BitArray, MaxVal)
-var AvoidOneZeroBitArrayMap = make(map[uint32]bitarray.BitArray)
-
-func init() {
-	// AllRows stores all rows without any 0-runs of
-	// size 1 or 2
-	for row := uint32(0); row < MaxVal; row++ {
-		boolArray := RowToArray(row)
-		curWhiteCount := 0
-		works := true
-		for i := 0; i < BoardSize; i++ {
-			if !boolArray[i] {
-				curWhiteCount += 1
-				if i < (BoardSize-1) && boolArray[i+1] {
-					if curWhiteCount < 3 {
-						works = false
-					}
-				}
-			} else {
-				curWhiteCount = 0
+var AllRows []Row
+var PossMiddleRows []Row
+var AllRowsBitArray bitarray.BitArray
+
+// whiteAt[i] and blackAt[i] are the indices into AllRows of the rows
+// that are white and black at square i
+var whiteAt []bitarray.BitArray
+var blackAt []bitarray.BitArray
+
+var cacheMutex sync.Mutex
+var avoidOneOneCache map[Row]bitarray.BitArray
+var avoidOneZeroCache map[Row]bitarray.BitArray
+
+// Config describes the boards we want tables for
+type Config struct {
+	Width  int
+	Height int
+	// MinLength is the shortest entry allowed, 0 means the usual 3
+	MinLength int
+	// MaxLength is the longest entry allowed, 0 means no limit
+	MaxLength int
+	// Symmetry is what every board has to look the same under
+	Symmetry Symmetry
+	// Shape has true for the squares outside the board, like ParseShape
+	// gives. They count as black for entries and connectivity but aren't
+	// part of the board. If it's set, it decides Width and Height too
+	Shape [][]bool
+	// Topology is which edges wrap around, Flat being none of them
+	Topology Topology
+	// EntryLengths makes Runs keep the exact length of every down run, so
+	// the DP can tell how long each entry is. It's slower
+	EntryLengths bool
+	// Forbidden has patterns that can't show up anywhere on a board
+	Forbidden []Pattern
+	// Components is how many pieces the white squares have to be in, 0
+	// means the usual 1 and AnyComponents means any number of them
+	Components int
+	// BlackRegions is how many black regions there have to be, or 0 for
+	// any number, and BlackBorder counts the edge of the board as one of
+	// them, see BlackReach
+	BlackRegions int
+	BlackBorder  bool
+	// Biconnected rules out white squares that split the white squares
+	// they're with into more pieces, see Cuts
+	Biconnected bool
+	// Motif is a pattern that has to show up on every board, at least
+	// once if MotifCount is 0 and exactly MotifCount times otherwise
+	Motif      Pattern
+	MotifCount int
+	// Partial is a board with some squares filled in already, which every
+	// board has to agree with, see FixedBlack
+	Partial Pattern
+	// Bounds limits how many black squares and words a board can have
+	Bounds Bounds
+	// MaxCheaters is the most cheaters a board can have, see Cheaters. 0
+	// means no limit, and NoCheaters means none at all
+	MaxCheaters int
+	// CountBlacks and CountWords have Count split the boards up by how
+	// many black squares and words they have, see Counts. It's slower, and
+	// Bounds turns them on by itself when it needs them
+	CountBlacks bool
+	CountWords  bool
+	// ReadingOrder keeps the rows of the board the way they were asked
+	// for, even when it's wider than it's tall and turning it sideways
+	// would make the DP quicker, so RankBoard and UnrankBoard can go
+	// through the boards in the order they'd be in as strings
+	ReadingOrder bool
+}
+
+// Init sets the board size and rebuilds every lookup table for it, so
+// it can be called again to move on to another size.
+func Init(cfg Config) error {
+	width, height := cfg.Width, cfg.Height
+	if cfg.Shape != nil {
+		height = len(cfg.Shape)
+		width = 0
+		if height > 0 {
+			width = len(cfg.Shape[0])
+		}
+		for _, row := range cfg.Shape {
+			if len(row) != width {
+				return fmt.Errorf("every row of a shape needs the same length")
 			}
 		}
-		if !boolArray[BoardSize-1] && curWhiteCount < 3 {
-			works = false
+		cfg.Width, cfg.Height = width, height
+	}
+	// The DP builds the board a row at a time, so it's cheapest when rows
+	// are short. All the rules are the same on the transposed board, so
+	// flip it if that gives us shorter rows. A cylinder would end up
+	// wrapping the wrong way though, and ReadingOrder needs the rows
+	// the way they are
+	Transposed = width > height && cfg.Topology != Cylinder && !cfg.ReadingOrder
+	sym := cfg.Symmetry.Closure()
+	if Transposed {
+		width, height = height, width
+		sym = sym.Transpose()
+	}
+	if cfg.ReadingOrder && width > MaxWidth {
+		return fmt.Errorf("board in reading order can be at most %v wide, got %vx%v", MaxWidth, cfg.Width, cfg.Height)
+	}
+	if width < 3 || height < 3 || width > MaxWidth {
+		return fmt.Errorf("board must be at least 3x3 with one side at most %v, got %vx%v", MaxWidth, cfg.Width, cfg.Height)
+	}
+	if width != height && sym&(Rot90|Diagonal|AntiDiagonal) != 0 {
+		return fmt.Errorf("symmetry %v needs a square board, got %vx%v", cfg.Symmetry, cfg.Width, cfg.Height)
+	}
+	for _, e := range sym.Elements() {
+		if !cfg.Topology.Keeps(e) {
+			return fmt.Errorf("symmetry %v doesn't work on a %v", cfg.Symmetry, cfg.Topology)
 		}
-		if works && row != MaxRow {
+	}
+	BoardTopology = cfg.Topology
+	BoardSymmetry = sym
+	BoardStrategy = strategyFor(sym)
+	MinLength = cfg.MinLength
+	if MinLength == 0 {
+		MinLength = 3
+	}
+	if MinLength < 1 || MinLength > 120 {
+		return fmt.Errorf("minimum entry length must be between 1 and 120, got %v", cfg.MinLength)
+	}
+	MaxLength = cfg.MaxLength
+	if MaxLength >= width && MaxLength >= height {
+		// nothing is long enough to hit the limit
+		MaxLength = 0
+	}
+	if MaxLength != 0 && (MaxLength < MinLength || MaxLength > 120) {
+		return fmt.Errorf("maximum entry length must be between %v and 120, got %v", MinLength, cfg.MaxLength)
+	}
+	Components = cfg.Components
+	if Components == 0 {
+		Components = 1
+	}
+	if Components < AnyComponents || Components > 255 {
+		return fmt.Errorf("number of components must be between 1 and 255, or %v for any, got %v", AnyComponents, cfg.Components)
+	}
+	BlackRegions, BlackBorder = cfg.BlackRegions, cfg.BlackBorder
+	Biconnected = cfg.Biconnected
+	if BlackRegions < 0 || BlackRegions >= borderLabel {
+		return fmt.Errorf("number of black regions must be between 0 and %v, got %v", borderLabel-1, cfg.BlackRegions)
+	}
+	MotifCount = cfg.MotifCount
+	if MotifCount < 0 || MotifCount > 254 {
+		return fmt.Errorf("number of motifs must be between 0 and 254, got %v", cfg.MotifCount)
+	}
+	maxCheaters = cfg.MaxCheaters
+	switch {
+	case maxCheaters == 0:
+		maxCheaters = -1
+	case maxCheaters == NoCheaters:
+		maxCheaters = 0
+	case maxCheaters < 0:
+		return fmt.Errorf("most cheaters must be at least 0, or NoCheaters, got %v", cfg.MaxCheaters)
+	}
+	if maxCheaters >= 0 && BoardTopology == Torus {
+		// a column that wraps around with just one black square has the
+		// same run on both sides of it, which the three rows the DP looks
+		// at can't tell, so find the boards one at a time instead
+		BoardStrategy = StrategySearch
+	}
+	bounds = cfg.Bounds
+	trackBlacks = cfg.CountBlacks || bounds.Blacks()
+	trackWords = cfg.CountWords || bounds.Words()
+	trackLengths = cfg.EntryLengths
+	if trackLengths && (bounds.Blacks() || bounds.Words()) {
+		return fmt.Errorf("entry lengths don't work with limits on black squares or words")
+	}
+	if cfg.EntryLengths && MaxLength == 0 {
+		// a limit nothing can reach still keeps the runs exact
+		MaxLength = max(width, height)
+	}
+	Width = width
+	Height = height
+	HalfHeight = Height
+	if Folded() {
+		HalfHeight = (Height + 1) / 2
+	}
+	MaxRow = Row{}
+	for i := 0; i < Width; i++ {
+		MaxRow = MaxRow.WithBit(i)
+	}
+	if err := initMask(cfg.Shape); err != nil {
+		return err
+	}
+	initPatterns(cfg.Forbidden, cfg.Motif)
+	if err := initPartial(cfg.Partial, cfg.Width, cfg.Height); err != nil {
+		return err
+	}
+	// the DP only keeps one of each board and its mirror when they're
+	// both boards or both not, which the shape, the patterns and the
+	// filled in squares can each break
+	MirrorPairs = keepsMask(MirrorLR) && keepsPatterns(MirrorLR) && keepsPartial(MirrorLR)
+	initDistSize()
+	ReachSize = Width
+
+	// AllRows stores all rows without any 0-runs shorter than MinLength
+	// (or longer than MaxLength). With left-right symmetry they're all
+	// palindromes, and we build each one from its left half, whose last
+	// run gets doubled up in the middle (sharing a square if Width is odd).
+	// When rows wrap around the runs at the two ends are really one run,
+	// so those get built separately
+	AllRows = nil
+	addRow := func(row Row) {
+		if row != MaxRow {
 			AllRows = append(AllRows, row)
 		}
 	}
-
-	MiddleRowMap = make(map[uint32]bool)
-	MiddleRowMap[MaxRow] = true
-
-	// Find all possible middle rows (palindromes)
-	for _, row := range AllRows {
-		arr := RowToArray(row)
-		works := true
-		for i := 0; i < BoardSize; i++ {
-			if arr[i] != arr[BoardSize-1-i] {
-				works = false
+	if wrapsAcross() {
+		forEachCyclicRow(func(row Row) {
+			if sym&MirrorLR == 0 || Reverse(row) == row {
+				addRow(row)
 			}
+		})
+	} else if sym&MirrorLR != 0 {
+		half := (Width + 1) / 2
+		lastMax := half
+		if MaxLength != 0 {
+			lastMax = (MaxLength + Width%2) / 2
 		}
-		// middle row cannot be all ones
-		if works && row != MaxRow {
-			PossMiddleRows = append(PossMiddleRows, row)
-			MiddleRowMap[row] = true
+		forEachRow(half, (MinLength+Width%2+1)/2, lastMax, func(left Row) {
+			addRow(left.Or(Reverse(left)))
+		})
+	} else {
+		lastMax := Width
+		if MaxLength != 0 {
+			lastMax = MaxLength
 		}
+		forEachRow(Width, MinLength, lastMax, addRow)
 	}
 
-	AllRows = PossMiddleRows
-
-	// Store a map of each row to its mirror image
-	RevMap = make(map[uint32]uint32)
-	RevMap[MaxRow] = MaxRow
+	// PossMiddleRows stores the rows that can go in the middle of a
+	// board with an odd height, which have to be their own Opposite
+	PossMiddleRows = nil
 	for _, row := range AllRows {
-		boolArray := RowToArray(row)
-		var revArray [BoardSize]bool
-		for i := uint32(0); i < BoardSize; i++ {
-			revArray[i] = boolArray[BoardSize-1-i]
+		if Opposite(row) == row {
+			PossMiddleRows = append(PossMiddleRows, row)
 		}
-		RevMap[row] = ArrayToRow(revArray)
 	}
 
-	// Get reach for each row
-	RowToReachMap = make(map[uint32][HalfBoardSize]uint8)
-	for _, row := range AllRows {
-		// make reachMap
-		islandCount := uint8(1)
-		var reach [HalfBoardSize]uint8
-		for i := uint8(0); i < BoardSize; i++ {
-			boolArray := RowToArray(row)
-			// if white, set island
-			if !boolArray[i] {
-				SetReachValueAtIndex(&reach, i, islandCount)
+	AllRowsBitArray = bitarray.NewSparseBitArray()
+	whiteAt = make([]bitarray.BitArray, Width)
+	blackAt = make([]bitarray.BitArray, Width)
+	for i := 0; i < Width; i++ {
+		whiteAt[i] = bitarray.NewSparseBitArray()
+		blackAt[i] = bitarray.NewSparseBitArray()
+	}
+	for index, row := range AllRows {
+		AllRowsBitArray.SetBit(uint64(index))
+		for i := 0; i < Width; i++ {
+			if row.Bit(i) {
+				blackAt[i].SetBit(uint64(index))
 			} else {
-				// black and prev was white
-				if i > 0 && !boolArray[i-1] {
-					islandCount += 1
-				}
+				whiteAt[i].SetBit(uint64(index))
 			}
 		}
-		RowToReachMap[row] = reach
 	}
+	avoidOneOneCache = make(map[Row]bitarray.BitArray)
+	avoidOneZeroCache = make(map[Row]bitarray.BitArray)
+	return nil
+}
 
-	// Get all rows that can be adjacent to a given middle row
-	PossFromMiddleMap = make(map[uint32]bitarray.BitArray)
-	for _, first := range PossMiddleRows {
-		PossFromMiddleMap[first] = bitarray.NewSparseBitArray()
-		for _, second := range AllRows {
-			if RevMap[second] & ^first & second == 0 {
-				PossFromMiddleMap[first].SetBit(second)
-			}
+// forEachRow calls f on every row of the given width with no white runs
+// shorter than MinLength or longer than MaxLength, except that a run
+// touching the right edge is between lastMin and lastMax long instead.
+// Rows get built a run at a time, so this only ever looks at valid rows.
+func forEachRow(width int, lastMin int, lastMax int, f func(row Row)) {
+	buildRows(Row{}, 0, width, func(last int) bool {
+		return last == 0 || last >= lastMin && last <= lastMax
+	}, f)
+}
+
+// forEachCyclicRow calls f on every row that's valid when its two ends
+// touch. We go by how many white squares there are before the first
+// black one, since those join up with the run at the right edge
+func forEachCyclicRow(f func(row Row)) {
+	for first := 0; first < Width; first++ {
+		if MaxLength != 0 && first > MaxLength {
+			break
 		}
+		buildRows(Row{}.WithBit(first), first+1, Width, func(last int) bool {
+			return isValidLength(first+last) && (MaxLength == 0 || first+last <= MaxLength)
+		}, f)
 	}
+	// no black squares at all is one long entry, like on a flat board
+	if Width >= MinLength && (MaxLength == 0 || Width <= MaxLength) {
+		f(Row{})
+	}
+}
 
-	// for binary x, stores all vals that dont share a 1 in the
-	// same position as x
-	AvoidOneOneBitarrayMap = make([]bitarray.BitArray, MaxVal)
-	for i := uint32(0); i < MaxVal; i++ {
-		AvoidOneOneBitarrayMap[i] = bitarray.NewSparseBitArray()
-		for _, int2 := range AllRows {
-			if i&int2 == 0 {
-				AvoidOneOneBitarrayMap[i].SetBit(int2)
+// buildRows fills in the squares of row from start to width a run at a
+// time, calling f on each row it finishes, as long as lastOK likes the
+// length of the white run touching the right edge
+func buildRows(row Row, start, width int, lastOK func(last int) bool, f func(row Row)) {
+	var build func(row Row, i int)
+	build = func(row Row, i int) {
+		if i >= width {
+			if lastOK(0) {
+				f(row)
+			}
+			return
+		}
+		// either a black square
+		build(row.WithBit(i), i+1)
+		// or a white run, followed by a black square or the edge
+		for end := i + 1; end <= width; end++ {
+			if end == width {
+				if lastOK(end - i) {
+					f(row)
+				}
+			} else if MaxLength != 0 && end-i > MaxLength {
+				break
+			} else if end-i >= MinLength {
+				build(row.WithBit(end), end+1)
 			}
 		}
 	}
+	build(row, start)
+}
 
-	// for binary x, stores all vals that dont have a 0 where
-	// x has a 1
-	AvoidOneZeroBitArrayMap = make(map[uint32]bitarray.BitArray)
-	for _, first := range AllRows {
-		AvoidOneZeroBitArrayMap[first] = bitarray.NewSparseBitArray()
-		for _, second := range AllRows {
-			if first & ^second > 0 {
-				// there's a 10, which is not possible
-			} else {
-				AvoidOneZeroBitArrayMap[first].SetBit(second)
+// PossFromMiddle gets all rows that can be adjacent to a given middle
+// row without leaving a down run of 1 through it. Any longer runs that
+// are too short get caught by ApplyDist
+func PossFromMiddle(middle Row) []Row {
+	if MinLength < 2 {
+		return AllRows
+	}
+	var ret []Row
+	for _, row := range AllRows {
+		if Opposite(row).AndNot(middle).And(row).IsZero() {
+			ret = append(ret, row)
+		}
+	}
+	return ret
+}
+
+// AvoidOneOne gets all rows that don't share a 1 in the same
+// position as key
+func AvoidOneOne(key Row) bitarray.BitArray {
+	return cachedAnd(avoidOneOneCache, key, whiteAt)
+}
+
+// AvoidOneZero gets all rows that don't have a 0 where row
+// has a 1
+func AvoidOneZero(row Row) bitarray.BitArray {
+	return cachedAnd(avoidOneZeroCache, row, blackAt)
+}
+
+// cachedAnd ands together columns[i] for every 1 in key
+func cachedAnd(cache map[Row]bitarray.BitArray, key Row, columns []bitarray.BitArray) bitarray.BitArray {
+	cacheMutex.Lock()
+	ret, exists := cache[key]
+	cacheMutex.Unlock()
+	if exists {
+		return ret
+	}
+	ret = AllRowsBitArray
+	for i := 0; i < Width; i++ {
+		if key.Bit(i) {
+			ret = ret.And(columns[i])
+		}
+	}
+	cacheMutex.Lock()
+	cache[key] = ret
+	cacheMutex.Unlock()
+	return ret
+}
+
+// ToRows converts one of the bitarrays above back into rows
+func ToRows(rows bitarray.BitArray) []Row {
+	nums := rows.ToNums()
+	ret := make([]Row, len(nums))
+	for i, num := range nums {
+		ret[i] = AllRows[num]
+	}
+	return ret
+}
+
+// RowToReach labels each white run in a row as its own component
+func RowToReach(row Row) Reach {
+	islandCount := uint8(1)
+	reach := make([]uint8, ReachSize)
+	for i := 0; i < Width; i++ {
+		// if white, set island
+		if !row.Bit(i) {
+			SetReachValueAtIndex(reach, i, islandCount)
+		} else {
+			// black and prev was white
+			if i > 0 && !row.Bit(i-1) {
+				islandCount += 1
 			}
 		}
 	}
+	if wrapsAcross() {
+		return joinAcross(Reach(reach))[0]
+	}
+	return Reach(reach)
 }
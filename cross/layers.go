@@ -0,0 +1,346 @@
+package cross
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// Layers keeps every layer of the DP instead of just the last one, for
+// the things that have to go back through it to find out about boards
+// one at a time, like SampleBoard. With MirrorPairs off, each board is one path through
+// the layers, from one of the starts to a state in the last layer that
+// end says is a whole board.
+type Layers struct {
+	// starts has the states the DP adds without coming from one in the
+	// layer before
+	starts []start
+	// dp[k] has the states after row number k, or nil before the DP
+	// gets going, and after[k] has how each of them can finish, with
+	// the black squares and words that adds, in the same sort of
+	// polynomial as the tallies
+	dp    []map[State]tally
+	after []map[State]Poly2
+	// next and end are the ones from runDP
+	next func(state State, index int, f func(row Row, next State, blacks, words int, lengths []int))
+	end  func(state State) (bool, int, []int)
+}
+
+// start is a state the DP starts from, which rows got it to, from row
+// number 0 on up, with blacks black squares and words words
+type start struct {
+	rows          []Row
+	state         State
+	blacks, words int
+}
+
+// CountLayers is Count, keeping every layer, with every board on a path
+// of its own. The layers are only good until Init gets called again.
+func CountLayers(cfg Config) (*Layers, error) {
+	if err := Init(cfg); err != nil {
+		return nil, err
+	}
+	// a board that stands in for its mirror too would be two boards on
+	// one path
+	MirrorPairs = false
+	var lay *Layers
+	if BoardStrategy == StrategySearch {
+		lay = searchLayers()
+	} else {
+		_, lay = runDP(true)
+	}
+	lay.backward()
+	return lay, nil
+}
+
+// backward fills in after, going back from the last layer to the first
+func (lay *Layers) backward() {
+	last := HalfHeight - 1
+	lay.after = make([]map[State]Poly2, HalfHeight)
+	lay.after[last] = make(map[State]Poly2)
+	for state := range lay.dp[last] {
+		if ok, words, _ := lay.end(state); ok {
+			lay.after[last][state] = Poly2(nil).AddShifted(oneBoard().boards, 0, words)
+		}
+	}
+	for index := last - 1; index >= 0 && lay.dp[index] != nil; index-- {
+		after := make(map[State]Poly2)
+		var keyList []State
+		for key := range lay.dp[index] {
+			keyList = append(keyList, key)
+		}
+		var mutex sync.Mutex
+		var wg sync.WaitGroup
+		wg.Add(numThreads)
+		for thread_id := 0; thread_id < numThreads; thread_id++ {
+			go func(thread_id int) {
+				defer wg.Done()
+				for ind, state := range keyList {
+					if ind%numThreads != thread_id {
+						continue
+					}
+					var p Poly2
+					lay.next(state, index+1, func(row Row, next State, blacks, words int, lengths []int) {
+						if q, ok := lay.after[index+1][next]; ok {
+							p = p.AddShifted(q, blacks, words)
+						}
+					})
+					if !p.IsZero() {
+						mutex.Lock()
+						after[state] = p
+						mutex.Unlock()
+					}
+				}
+			}(thread_id)
+		}
+		wg.Wait()
+		lay.after[index] = after
+	}
+}
+
+// inBounds is how many of the boards p counts are in bounds, once they
+// get blacks more black squares and words more words
+func inBounds(p Poly2, blacks, words int) *big.Int {
+	minBlacks, maxBlacks := bounds.BlackRange(0)
+	minWords, maxWords := bounds.WordRange(0)
+	return p.SumIn(minBlacks-blacks, maxBlacks-blacks, minWords-words, maxWords-words)
+}
+
+// weight is how many boards go through start s
+func (lay *Layers) weight(s start) *big.Int {
+	return inBounds(lay.after[len(s.rows)-1][s.state], s.blacks, s.words)
+}
+
+// Total is how many boards there are, in bounds
+func (lay *Layers) Total() *big.Int {
+	ret := new(big.Int)
+	for _, s := range lay.starts {
+		ret.Add(ret, lay.weight(s))
+	}
+	return ret
+}
+
+// BlackCounts counts how many boards have each square black, the way the
+// board was asked for, with nil for the squares outside the shape. Every
+// board goes through one state in each layer past its start, which has
+// the row it has there, so that's the boards that get to the state times
+// the ways to finish from it. The rows before that are in the start.
+func (lay *Layers) BlackCounts() [][]*big.Int {
+	counts := make([][]*big.Int, Height)
+	for i := range counts {
+		counts[i] = make([]*big.Int, Width)
+		for j := range counts[i] {
+			if !Mask[i].Bit(j) {
+				counts[i][j] = new(big.Int)
+			}
+		}
+	}
+	// add adds n boards with row as row number index
+	add := func(index int, row Row, n *big.Int) {
+		i := DPRow(index)
+		rows := map[int]Row{i: row}
+		if Folded() {
+			rows[Height-1-i] = Opposite(row)
+		}
+		for i, row := range rows {
+			for j := 0; j < Width; j++ {
+				if row.Bit(j) && !Mask[i].Bit(j) {
+					counts[i][j].Add(counts[i][j], n)
+				}
+			}
+		}
+	}
+	for _, s := range lay.starts {
+		n := lay.weight(s)
+		for index, row := range s.rows[:len(s.rows)-1] {
+			add(index, row, n)
+		}
+	}
+	minBlacks, maxBlacks := bounds.BlackRange(0)
+	minWords, maxWords := bounds.WordRange(0)
+	for index, dp := range lay.dp {
+		for state, t := range dp {
+			if after, ok := lay.after[index][state]; ok {
+				add(index, state.lastRow, t.boards.MulSumIn(after, minBlacks, maxBlacks, minWords, maxWords))
+			}
+		}
+	}
+	if !Transposed {
+		return counts
+	}
+	transposed := make([][]*big.Int, Width)
+	for j := range transposed {
+		transposed[j] = make([]*big.Int, Height)
+		for i := range transposed[j] {
+			transposed[j][i] = counts[i][j]
+		}
+	}
+	return transposed
+}
+
+// A walk goes through the layers from a start to the last layer, the way
+// the DP got to one of its boards. choice is a way it can go on from
+// where it's got to: the rows that adds, the state they get to with
+// blacks black squares and words words so far, and how many boards in
+// bounds go that way.
+type choice struct {
+	rows          []Row
+	state         State
+	blacks, words int
+	n             *big.Int
+}
+
+// walk picks one of the starts and then a row for each layer after it,
+// with pick getting the choices that have any boards, in order by their
+// rows, along with the row number of the first row they add. It comes
+// back with the rows from row number 0 on up, or nil if pick gives up
+// by picking -1.
+func (lay *Layers) walk(pick func(index int, choices []choice) int) []Row {
+	var choices []choice
+	for _, s := range lay.starts {
+		if n := lay.weight(s); n.Sign() != 0 {
+			choices = append(choices, choice{s.rows, s.state, s.blacks, s.words, n})
+		}
+	}
+	var rows []Row
+	for index := 0; index < HalfHeight; index = len(rows) {
+		sort.Slice(choices, func(a, b int) bool {
+			return rowsLess(choices[a].rows, choices[b].rows)
+		})
+		k := pick(index, choices)
+		if k < 0 {
+			return nil
+		}
+		c := choices[k]
+		rows = append(rows, c.rows...)
+		choices = nil
+		if len(rows) == HalfHeight {
+			break
+		}
+		lay.next(c.state, len(rows), func(row Row, next State, blacks, words int, lengths []int) {
+			if after, ok := lay.after[len(rows)][next]; ok {
+				blacks, words = c.blacks+blacks, c.words+words
+				if n := inBounds(after, blacks, words); n.Sign() != 0 {
+					choices = append(choices, choice{[]Row{row}, next, blacks, words, n})
+				}
+			}
+		})
+	}
+	return rows
+}
+
+// rowsLess orders starts by their rows, the first row first, each in
+// reading order. A start never has the rows of another one and then
+// some, so it never has to compare a row with one that isn't there
+func rowsLess(a, b []Row) bool {
+	for k := 0; k < len(a) && k < len(b); k++ {
+		if a[k] != b[k] {
+			return a[k].ReadingLess(b[k])
+		}
+	}
+	return false
+}
+
+// walkInOrder is walk with the boards in the order they'd be in as
+// strings, top row first. That's the order walk goes in when the DP
+// starts from the top, but a folded board starts from the middle, so
+// that takes walkBack.
+func (lay *Layers) walkInOrder(pick func(index int, choices []choice) int) []Row {
+	if Folded() {
+		return lay.walkBack(pick)
+	}
+	return lay.walk(pick)
+}
+
+// walkBack is walk for a folded board, going back from the last layer to
+// the first, so from the top row down to the middle. Each choice is a
+// single row, with how many boards have it there under the rows already
+// picked. The states in a layer that the rows picked so far can come
+// from are live, with how they can finish given those rows, like after.
+// A start the walk has gone back past is pending, since it already has
+// every row under its layer, and it's one board in bounds or none.
+func (lay *Layers) walkBack(pick func(index int, choices []choice) int) []Row {
+	last := HalfHeight - 1
+	// starts[k] has the starts in layer k by their state
+	starts := make([]map[State][]start, HalfHeight)
+	for _, s := range lay.starts {
+		k := len(s.rows) - 1
+		if starts[k] == nil {
+			starts[k] = make(map[State][]start)
+		}
+		starts[k][s.state] = append(starts[k][s.state], s)
+	}
+	live := make(map[State]Poly2)
+	for state := range lay.dp[last] {
+		if ok, words, _ := lay.end(state); ok {
+			live[state] = Poly2(nil).AddShifted(oneBoard().boards, 0, words)
+		}
+	}
+	var pending []start
+	minBlacks, maxBlacks := bounds.BlackRange(0)
+	minWords, maxWords := bounds.WordRange(0)
+	rows := make([]Row, HalfHeight)
+	for index := last; index >= 0; index-- {
+		boards := make(map[Row]*big.Int)
+		add := func(row Row, n *big.Int) {
+			if boards[row] == nil {
+				boards[row] = new(big.Int)
+			}
+			boards[row].Add(boards[row], n)
+		}
+		for state, after := range live {
+			add(state.lastRow, lay.dp[index][state].boards.MulSumIn(after, minBlacks, maxBlacks, minWords, maxWords))
+		}
+		for _, s := range pending {
+			add(s.rows[index], big.NewInt(1))
+		}
+		var choices []choice
+		for row, n := range boards {
+			if n.Sign() != 0 {
+				choices = append(choices, choice{rows: []Row{row}, n: n})
+			}
+		}
+		sort.Slice(choices, func(a, b int) bool {
+			return choices[a].rows[0].ReadingLess(choices[b].rows[0])
+		})
+		k := pick(index, choices)
+		if k < 0 {
+			return nil
+		}
+		row := choices[k].rows[0]
+		rows[index] = row
+		kept := pending[:0]
+		for _, s := range pending {
+			if s.rows[index] == row {
+				kept = append(kept, s)
+			}
+		}
+		for state, after := range live {
+			if state.lastRow != row {
+				continue
+			}
+			for _, s := range starts[index][state] {
+				if inBounds(after, s.blacks, s.words).Sign() != 0 {
+					kept = append(kept, s)
+				}
+			}
+		}
+		pending = kept
+		next := make(map[State]Poly2)
+		if index > 0 {
+			for state := range lay.dp[index-1] {
+				var p Poly2
+				lay.next(state, index, func(r Row, to State, blacks, words int, lengths []int) {
+					if q, ok := live[to]; ok && r == row {
+						p = p.AddShifted(q, blacks, words)
+					}
+				})
+				if !p.IsZero() {
+					next[state] = p
+				}
+			}
+		}
+		live = next
+	}
+	return rows
+}
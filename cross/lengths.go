@@ -0,0 +1,136 @@
+package cross
+
+// LengthStats keeps track of the entry lengths of a bunch of boards. The
+// DP only knows how long a down entry is if Runs has its exact length, so
+// these need Config.EntryLengths.
+type LengthStats struct {
+	// Longest has how many of the boards have their longest entry so far
+	// of each length, as the coefficient of x^length
+	Longest Poly
+	// Entries has the total number of entries of each length, over all
+	// the boards
+	Entries Poly
+}
+
+// OneBoard is the stats for a single board with no entries yet
+func OneBoard() LengthStats {
+	return LengthStats{Longest: Monomial(1, 0)}
+}
+
+// Add adds the boards of t to s, where each of them gets some more
+// entries with the given lengths, and returns the result. Like
+// Poly.AddShifted it changes s in place.
+func (s LengthStats) Add(t LengthStats, lengths []int) LengthStats {
+	longest := 0
+	for _, length := range lengths {
+		longest = max(longest, length)
+	}
+	for k, c := range t.Longest {
+		s.Longest = s.Longest.AddShifted(Poly{c}, max(k, longest))
+	}
+	s.Entries = s.Entries.AddShifted(t.Entries, 0)
+	boards := t.Longest.Sum()
+	for _, length := range lengths {
+		s.Entries = s.Entries.AddShifted(Poly{boards}, length)
+	}
+	return s
+}
+
+// RowEntryLengths appends the length of each across entry in row to
+// lengths
+func RowEntryLengths(lengths []int, row Row) []int {
+	forEachRun(row.Bit, Width, wrapsAcross(), func(run int) {
+		lengths = append(lengths, run)
+	})
+	return lengths
+}
+
+// FinishedRuns appends the lengths of the down entries that end when row
+// goes on top of runs as row number index, counting out from the middle,
+// to lengths. When the board is built out from the middle each entry in
+// the top half has one just like it in the bottom half, so those go in
+// twice. On a torus built from the top, the entry at the top of a column
+// carries on from the bottom, so EndRuns gets that one.
+func FinishedRuns(lengths []int, runs Runs, topRuns TopRuns, row Row, index int) []int {
+	open := runsOpen()
+	for i := 0; i < Width; i++ {
+		if !row.Bit(i) {
+			continue
+		}
+		opposite := oppositeColumn(i)
+		switch {
+		case !Folded():
+			if runs[i] > 0 && (topRuns == "" || topRuns[i] != topRunsOpen()) {
+				lengths = append(lengths, int(runs[i]))
+			}
+		case runs[i] < open:
+			if runs[i] > 0 {
+				lengths = append(lengths, int(runs[i]), int(runs[i]))
+			}
+		case runs[i] > open:
+			// the opposite column already closed, so the entries through
+			// the middle of both columns end now
+			length := middleRunLength(index, int(runs[i]-open-1))
+			if length > 0 {
+				lengths = append(lengths, length, length)
+			}
+		case opposite == i || row.Bit(opposite):
+			// the opposite column closes now too, and gets its own entry
+			// when the loop gets to it
+			if length := middleRunLength(index, index); length > 0 {
+				lengths = append(lengths, length)
+			}
+		}
+	}
+	return lengths
+}
+
+// EndRuns appends the lengths of the down entries that are still going
+// after the last row, which is the top row for boards built out from the
+// middle, to lengths. Those end at the edge of the board, or on a torus
+// they carry on from the other edge.
+func EndRuns(lengths []int, runs Runs, topRuns TopRuns) []int {
+	open := runsOpen()
+	for i := 0; i < Width; i++ {
+		opposite := oppositeColumn(i)
+		length, copies := 0, 1
+		switch {
+		case !Folded() && wrapsDown():
+			// the run at the bottom carries on into the run at the top,
+			// unless the column is white all the way around
+			length = int(topRuns[i]) + int(runs[i])
+			if topRuns[i] == topRunsOpen() {
+				length = Height
+			}
+		case !Folded():
+			length = int(runs[i])
+		case runs[i] == open:
+			length = Height
+		case runs[i] > open:
+			// the run through the middle goes to the top, and on a torus
+			// it comes back up from the bottom, which is the top of the
+			// opposite column upside down
+			length, copies = middleRunLength(HalfHeight, int(runs[i]-open-1)), 2
+			if wrapsDown() {
+				length += int(runs[opposite])
+			}
+		case !wrapsDown():
+			length, copies = int(runs[i]), 2
+		case runs[opposite] < open:
+			length = int(runs[i]) + int(runs[opposite])
+		}
+		for ; length > 0 && copies > 0; copies-- {
+			lengths = append(lengths, length)
+		}
+	}
+	return lengths
+}
+
+// BoardEntryLengths appends the length of every entry in a whole board to
+// lengths
+func BoardEntryLengths(lengths []int, board []Row) []int {
+	forEachBoardRun(board, func(run int) {
+		lengths = append(lengths, run)
+	})
+	return lengths
+}
@@ -0,0 +1,84 @@
+package cross
+
+import "fmt"
+
+// A partial board has some of its squares filled in already, like a
+// constructor who's put in the theme entries and a few black squares
+// first, and only the boards that agree with it count. It's written as a
+// Pattern the size of the board, with ? for the squares that aren't
+// filled in. FixedBlack and FixedWhite have the squares of each row that
+// have to be black and white, the way the board is stored, or nothing
+// when there's no partial board. A square the symmetry ties to a filled
+// in one gets filled in the same way, and it's an error for a square to
+// end up both black and white. Squares outside the shape don't count.
+var FixedBlack []Row
+var FixedWhite []Row
+
+// ParsePartial reads a partial board the way ParsePatterns reads a
+// pattern
+func ParsePartial(s string) (Pattern, error) {
+	return parseOne(s, "a partial board")
+}
+
+// initPartial fills in FixedBlack and FixedWhite from a partial board the
+// way Config has it, which still needs transposing if the board does
+func initPartial(partial Pattern, width, height int) error {
+	FixedBlack = make([]Row, Height)
+	FixedWhite = make([]Row, Height)
+	if partial == nil {
+		return nil
+	}
+	if len(partial) != height || len(partial[0]) != width {
+		return fmt.Errorf("partial board is %vx%v but the board is %vx%v", len(partial[0]), len(partial), width, height)
+	}
+	if Transposed {
+		partial = partial.Transform(Diagonal)
+	}
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			if Mask[i].Bit(j) || partial[i][j] == '?' {
+				continue
+			}
+			fixed := FixedWhite
+			if partial[i][j] == '#' {
+				fixed = FixedBlack
+			}
+			fixed[i] = fixed[i].WithBit(j)
+			for _, e := range BoardSymmetry.Elements() {
+				a, b := e.Apply(i, j)
+				fixed[a] = fixed[a].WithBit(b)
+			}
+		}
+	}
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			if FixedBlack[i].Bit(j) && FixedWhite[i].Bit(j) {
+				if Transposed {
+					i, j = j, i
+				}
+				return fmt.Errorf("partial board has the square in row %v column %v both black and white once the symmetry fills it in", i+1, j+1)
+			}
+		}
+	}
+	return nil
+}
+
+// FitsPartial checks that row agrees with the squares of row i of the
+// board that are filled in already
+func FitsPartial(row Row, i int) bool {
+	return row.And(FixedBlack[i]) == FixedBlack[i] && row.And(FixedWhite[i]).IsZero()
+}
+
+// keepsPartial checks that the single transformation g, on the board as
+// it's stored, sends the partial board to itself
+func keepsPartial(g Symmetry) bool {
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			a, b := g.Apply(i, j)
+			if FixedBlack[i].Bit(j) != FixedBlack[a].Bit(b) || FixedWhite[i].Bit(j) != FixedWhite[a].Bit(b) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package cross
+
+import "testing"
+
+func TestPartialContradiction(t *testing.T) {
+	for _, c := range []struct {
+		width, height int
+		sym           Symmetry
+		partial       Pattern
+		ok            bool
+	}{
+		{5, 5, Rot180, Pattern{"#????", "?????", "?????", "?????", "????."}, false},
+		{5, 5, Rot180, Pattern{"#????", "?????", "?????", "?????", "????#"}, true},
+		{5, 5, MirrorLR, Pattern{"#????", "?????", "?????", "?????", "????."}, true},
+		// stored transposed, since it's wider than it is tall
+		{4, 3, Rot180, Pattern{"?#??", "????", "??.?"}, false},
+		{4, 3, 0, Pattern{"?#??", "????", "??.?"}, true},
+	} {
+		err := Init(Config{Width: c.width, Height: c.height, Symmetry: c.sym, Partial: c.partial})
+		if (err == nil) != c.ok {
+			t.Errorf("%v with %v: got error %v", c.partial, c.sym, err)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+package cross
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Pattern is a block of squares that can't show up anywhere on a board,
+// usually k x k, as a string per row. # is a black square, . is a white
+// one and ? can be either, so a 2x2 block of # rules out clumps of black
+// squares, and #. over .# rules out black squares touching only at a
+// corner that way. Squares outside the shape don't count as black for
+// these.
+type Pattern []string
+
+// PatternHeight is how many rows the tallest forbidden pattern or the
+// motif has, so it's how many rows the DP has to look at to spot one.
+// It's 0 when there aren't any.
+var PatternHeight int
+
+// placement is a forbidden pattern at one column of the board, with the
+// squares it needs black and white in each of its rows
+type placement struct {
+	black []Row
+	white []Row
+}
+
+var placements []placement
+
+// forbidden has the patterns the way the board is stored
+var forbidden []Pattern
+
+// A motif is a pattern that has to show up somewhere on a board, at least
+// once if MotifCount is 0, or exactly MotifCount times otherwise, where
+// every place it fits counts. motif is the way the board is stored, and
+// it's nil if there isn't one.
+var motif Pattern
+var motifPlacements []placement
+
+// ParsePatterns reads patterns separated by blank lines, each with a line
+// per row using the characters Pattern does
+func ParsePatterns(s string) ([]Pattern, error) {
+	var patterns []Pattern
+	var pattern Pattern
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if pattern != nil {
+				patterns = append(patterns, pattern)
+			}
+			pattern = nil
+			continue
+		}
+		if strings.Trim(line, "#.?") != "" {
+			return nil, fmt.Errorf("patterns can only have #, . and ?, got %q", line)
+		}
+		if len(pattern) > 0 && len(line) != len(pattern[0]) {
+			return nil, fmt.Errorf("every row of a pattern needs the same length, %q isn't %v long", line, len(pattern[0]))
+		}
+		pattern = append(pattern, line)
+	}
+	if pattern != nil {
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// ParseMotif reads a single pattern the way ParsePatterns does
+func ParseMotif(s string) (Pattern, error) {
+	return parseOne(s, "a motif")
+}
+
+// parseOne reads a single pattern for what, which is all s can have
+func parseOne(s, what string) (Pattern, error) {
+	patterns, err := ParsePatterns(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) != 1 {
+		return nil, fmt.Errorf("%v needs exactly one pattern, got %v", what, len(patterns))
+	}
+	return patterns[0], nil
+}
+
+// Transform gets the pattern that the single transformation g turns p
+// into
+func (p Pattern) Transform(g Symmetry) Pattern {
+	height, width := len(p), len(p[0])
+	newHeight, newWidth := height, width
+	if g&(Rot90|Rot270|Diagonal|AntiDiagonal) != 0 {
+		newHeight, newWidth = width, height
+	}
+	squares := make([][]byte, newHeight)
+	for i := range squares {
+		squares[i] = make([]byte, newWidth)
+	}
+	for i := 0; i < height; i++ {
+		for j := 0; j < width; j++ {
+			a, b := g.applyIn(i, j, width, height)
+			squares[a][b] = p[i][j]
+		}
+	}
+	ret := make(Pattern, newHeight)
+	for i, row := range squares {
+		ret[i] = string(row)
+	}
+	return ret
+}
+
+// initPatterns works out where each of the patterns and the motif can go
+// on the board, after any transposing
+func initPatterns(patterns []Pattern, m Pattern) {
+	forbidden = nil
+	placements = nil
+	motif = nil
+	motifPlacements = nil
+	PatternHeight = 0
+	for _, p := range patterns {
+		if Transposed {
+			p = p.Transform(Diagonal)
+		}
+		forbidden = append(forbidden, p)
+		placements = append(placements, placePattern(p)...)
+	}
+	if m != nil {
+		motif = m
+		if Transposed {
+			motif = m.Transform(Diagonal)
+		}
+		motifPlacements = placePattern(motif)
+	}
+}
+
+// placePattern gets every place p can go on the board. A pattern too big
+// to fit never shows up, and on a cylinder or torus it can wrap around as
+// long as it doesn't run into itself.
+func placePattern(p Pattern) []placement {
+	height, width := len(p), len(p[0])
+	if height > Height || width > Width {
+		return nil
+	}
+	PatternHeight = max(PatternHeight, height)
+	lastColumn := Width - width
+	if wrapsAcross() {
+		lastColumn = Width - 1
+	}
+	var ret []placement
+	for c := 0; c <= lastColumn; c++ {
+		place := placement{make([]Row, height), make([]Row, height)}
+		for i := 0; i < height; i++ {
+			for j := 0; j < width; j++ {
+				switch p[i][j] {
+				case '#':
+					place.black[i] = place.black[i].WithBit((c + j) % Width)
+				case '.':
+					place.white[i] = place.white[i].WithBit((c + j) % Width)
+				}
+			}
+		}
+		ret = append(ret, place)
+	}
+	return ret
+}
+
+// keepsPatterns checks that the single transformation g, on the board
+// as it's stored, sends the forbidden patterns to themselves and the
+// motif to itself, so it sends boards without them to boards without
+// them, and keeps how many times the motif shows up
+func keepsPatterns(g Symmetry) bool {
+	set := make(map[string]bool)
+	for _, p := range forbidden {
+		set[strings.Join(p, "\n")] = true
+	}
+	for _, p := range forbidden {
+		if !set[strings.Join(p.Transform(g), "\n")] {
+			return false
+		}
+	}
+	return motif == nil || strings.Join(motif.Transform(g), "\n") == strings.Join(motif, "\n")
+}
+
+// matches says whether the pattern is at row top of the board, with
+// rowAt giving the rows it covers. Those wrap around on a torus, and it
+// can't run off the bottom otherwise
+func (p placement) matches(top int, rowAt func(i int) Row) bool {
+	for k := range p.black {
+		i := top + k
+		if wrapsDown() {
+			i %= Height
+		} else if i >= Height {
+			return false
+		}
+		if !p.black[k].Or(p.white[k]).And(Mask[i]).IsZero() {
+			return false
+		}
+		row := rowAt(i)
+		if row.And(p.black[k]) != p.black[k] || !row.And(p.white[k]).IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// ForbiddenFrom says whether a forbidden pattern has its top row at row
+// top of the board and ends by row last, with rowAt giving the rows in
+// between
+func ForbiddenFrom(top, last int, rowAt func(i int) Row) bool {
+	return matchesFrom(placements, top, last, rowAt) > 0
+}
+
+// ForbiddenTo says whether a forbidden pattern has its bottom row at row
+// bottom of the board and starts from row first on
+func ForbiddenTo(first, bottom int, rowAt func(i int) Row) bool {
+	return matchesTo(placements, first, bottom, rowAt) > 0
+}
+
+// ForbiddenAcrossEdge says whether a forbidden pattern on a torus runs
+// off the bottom of the board and carries on from the top
+func ForbiddenAcrossEdge(rowAt func(i int) Row) bool {
+	return matchesAcrossEdge(placements, rowAt) > 0
+}
+
+// HasForbidden checks a whole board for forbidden patterns
+func HasForbidden(board []Row) bool {
+	return boardMatches(placements, board) > 0
+}
+
+// AddsPattern says whether adding a row as row number index, counting
+// out from the middle, finishes a forbidden pattern. rows has that row
+// first and then the rows added before it, going back PatternHeight-1
+// rows or to row 0. Building out from the middle, the new row's
+// opposite can finish one too.
+func AddsPattern(rows []Row, index int) bool {
+	return addedMatches(placements, rows, index) > 0
+}
+
+// EndsInPattern is AddsPattern for the patterns that go across the edge
+// of a torus, once the DP is done. rows has the last row it added first,
+// and first has the top rows, newest first, for a torus built from the
+// top
+func EndsInPattern(rows, first []Row) bool {
+	return ForbiddenAcrossEdge(dpRowAt(rows, HalfHeight-1, first))
+}
+
+// HasMotif says whether there's a motif to look for
+func HasMotif() bool {
+	return motif != nil
+}
+
+// MotifCap is the most motifs the DP has to keep count of. Past that a
+// board has too many when it needs exactly MotifCount, and when it needs
+// at least one, one is as good as any more.
+func MotifCap() int {
+	if MotifCount == 0 {
+		return 1
+	}
+	return MotifCount + 1
+}
+
+// CanHaveMotifs says whether a board that has n motifs so far can still
+// end up with the right number
+func CanHaveMotifs(n int) bool {
+	return MotifCount == 0 || n <= MotifCount
+}
+
+// HasMotifs says whether a finished board with n motifs has the right
+// number of them
+func HasMotifs(n int) bool {
+	if !HasMotif() {
+		return true
+	}
+	if MotifCount == 0 {
+		return n > 0
+	}
+	return n == MotifCount
+}
+
+// AddsMotifs is AddsPattern for the motif, and says how many times adding
+// the row finishes it, up to MotifCap
+func AddsMotifs(rows []Row, index int) int {
+	return min(addedMatches(motifPlacements, rows, index), MotifCap())
+}
+
+// EndsInMotifs is EndsInPattern for the motif
+func EndsInMotifs(rows, first []Row) int {
+	return min(matchesAcrossEdge(motifPlacements, dpRowAt(rows, HalfHeight-1, first)), MotifCap())
+}
+
+// BoardMotifs is how many times the motif shows up on a whole board
+func BoardMotifs(board []Row) int {
+	return boardMatches(motifPlacements, board)
+}
+
+// matchesFrom is how many of places have their top row at row top of the
+// board and end by row last
+func matchesFrom(places []placement, top, last int, rowAt func(i int) Row) int {
+	n := 0
+	for _, p := range places {
+		if top+len(p.black)-1 <= last && p.matches(top, rowAt) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesTo is how many of places have their bottom row at row bottom of
+// the board and start from row first on
+func matchesTo(places []placement, first, bottom int, rowAt func(i int) Row) int {
+	n := 0
+	for _, p := range places {
+		top := bottom - len(p.black) + 1
+		if top >= first && p.matches(top, rowAt) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesAcrossEdge is how many of places run off the bottom of a torus
+// and carry on from the top
+func matchesAcrossEdge(places []placement, rowAt func(i int) Row) int {
+	if !wrapsDown() {
+		return 0
+	}
+	n := 0
+	for top := Height - PatternHeight + 1; top < Height; top++ {
+		for _, p := range places {
+			if top+len(p.black) > Height && p.matches(top, rowAt) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// boardMatches is how many of places a whole board has
+func boardMatches(places []placement, board []Row) int {
+	rowAt := func(i int) Row { return board[i] }
+	n := 0
+	for top := 0; top < Height; top++ {
+		n += matchesFrom(places, top, Height-1, rowAt)
+	}
+	return n + matchesAcrossEdge(places, rowAt)
+}
+
+// addedMatches is how many of places adding a row finishes, like
+// AddsPattern. Building out from the middle, one that the new row and
+// its opposite both finish only counts once.
+func addedMatches(places []placement, rows []Row, index int) int {
+	rowAt := dpRowAt(rows, index, nil)
+	oldest := index - len(rows) + 1
+	if !Folded() {
+		return matchesTo(places, oldest, index, rowAt)
+	}
+	top := DPRow(index)
+	last := DPRow(oldest)
+	if oldest == 0 {
+		// the rows go through the middle and back out the other side
+		last = Height - 1 - top
+	}
+	return matchesFrom(places, top, last, rowAt) + matchesTo(places, max(Height-1-last, top+1), Height-1-top, rowAt)
+}
+
+// dpRowAt gets row i of the board from the last few rows the DP added,
+// like AddsPattern has them, and the top rows for a torus built from the
+// top
+func dpRowAt(rows []Row, index int, first []Row) func(i int) Row {
+	return func(i int) Row {
+		if !Folded() {
+			if i > index-len(rows) {
+				return rows[index-i]
+			}
+			return first[len(first)-1-i]
+		}
+		if i >= HalfHeight {
+			return Opposite(rows[index-HalfHeight+1+(Height-1-i)])
+		}
+		return rows[index-HalfHeight+1+i]
+	}
+}
+
+// rowBytes is how many bytes RecentRows takes for each row
+const rowBytes = RowWords * 8
+
+// RecentRows packs a few rows into a string, newest first, so the DP can
+// keep the rows it still needs to spot forbidden patterns in a map key
+// like Runs
+type RecentRows string
+
+// Push puts row in front of rows, keeping the newest keep of them
+func (rows RecentRows) Push(row Row, keep int) RecentRows {
+	if keep <= 0 {
+		return ""
+	}
+	b := make([]byte, 0, len(rows)+rowBytes)
+	for _, word := range row {
+		b = binary.LittleEndian.AppendUint64(b, word)
+	}
+	b = append(b, rows...)
+	return RecentRows(b[:min(len(b), keep*rowBytes)])
+}
+
+// Rows unpacks rows, newest first
+func (rows RecentRows) Rows() []Row {
+	ret := make([]Row, len(rows)/rowBytes)
+	for k := range ret {
+		for w := range ret[k] {
+			ret[k][w] = binary.LittleEndian.Uint64([]byte(rows[k*rowBytes+w*8:]))
+		}
+	}
+	return ret
+}
@@ -0,0 +1,127 @@
+package cross
+
+import (
+	"math/big"
+)
+
+// Poly is a polynomial with big coefficients, where p[k] is the
+// coefficient of x^k. The DP uses them to count boards by how many black
+// squares they have, with x^k standing for k black squares. nil is zero.
+type Poly []*big.Int
+
+// Monomial is c times x^k
+func Monomial(c int64, k int) Poly {
+	return Poly(nil).AddShifted(Poly{big.NewInt(c)}, k)
+}
+
+// AddShifted adds q times x^shift to p and returns the result. It changes
+// p in place, but never shares coefficients with q
+func (p Poly) AddShifted(q Poly, shift int) Poly {
+	for len(p) < len(q)+shift {
+		p = append(p, new(big.Int))
+	}
+	for k, c := range q {
+		p[k+shift].Add(p[k+shift], c)
+	}
+	return p
+}
+
+// Sum adds up the coefficients, which is the count ignoring x
+func (p Poly) Sum() *big.Int {
+	ret := new(big.Int)
+	for _, c := range p {
+		ret.Add(ret, c)
+	}
+	return ret
+}
+
+// Poly2 is a polynomial in two variables, where p[j] is the coefficient
+// of y^j, which is a polynomial in x. The DP uses x for black squares
+// like in Poly, and y for words.
+type Poly2 []Poly
+
+// AddShifted adds q times x^xShift y^yShift to p and returns the result,
+// the same way Poly's does
+func (p Poly2) AddShifted(q Poly2, xShift, yShift int) Poly2 {
+	for len(p) < len(q)+yShift {
+		p = append(p, nil)
+	}
+	for j, c := range q {
+		p[j+yShift] = p[j+yShift].AddShifted(c, xShift)
+	}
+	return p
+}
+
+// SumY adds up the coefficients of each power of y, which leaves the
+// polynomial in x
+func (p Poly2) SumY() Poly {
+	var ret Poly
+	for _, c := range p {
+		ret = ret.AddShifted(c, 0)
+	}
+	return ret
+}
+
+// Sum adds up the coefficients, which is the count ignoring x and y
+func (p Poly2) Sum() *big.Int {
+	return p.SumY().Sum()
+}
+
+// Clip drops the terms of p with a power of x outside minX to maxX or a
+// power of y outside minY to maxY, and returns the result. It changes p
+// in place
+func (p Poly2) Clip(minX, maxX, minY, maxY int) Poly2 {
+	for j := range p {
+		if j < minY || j > maxY {
+			p[j] = nil
+			continue
+		}
+		for k, c := range p[j] {
+			if k < minX || k > maxX {
+				c.SetInt64(0)
+			}
+		}
+	}
+	return p
+}
+
+// IsZero says whether every coefficient of p is 0
+func (p Poly2) IsZero() bool {
+	for _, c := range p {
+		for _, d := range c {
+			if d.Sign() != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// SumIn adds up the coefficients of the terms of p with a power of x from
+// minX to maxX and a power of y from minY to maxY
+func (p Poly2) SumIn(minX, maxX, minY, maxY int) *big.Int {
+	ret := new(big.Int)
+	for j := max(minY, 0); j <= maxY && j < len(p); j++ {
+		for k := max(minX, 0); k <= maxX && k < len(p[j]); k++ {
+			ret.Add(ret, p[j][k])
+		}
+	}
+	return ret
+}
+
+// MulSumIn is SumIn for p times q, without working out the rest of the
+// product
+func (p Poly2) MulSumIn(q Poly2, minX, maxX, minY, maxY int) *big.Int {
+	ret := new(big.Int)
+	var term big.Int
+	for j, c := range p {
+		for k, d := range c {
+			if d.Sign() == 0 {
+				continue
+			}
+			term.Mul(d, q.SumIn(minX-k, maxX-k, minY-j, maxY-j))
+			ret.Add(ret, &term)
+		}
+	}
+	return ret
+}
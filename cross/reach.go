@@ -6,36 +6,41 @@ import (
 	"strings"
 )
 
-const ReachSize = HalfBoardSize
+// ReachSize is the number of bytes in a Reach, set by Init
+var ReachSize int
 
-func GetReachValueAtIndex(reach [ReachSize]uint8, i uint8) uint8 {
-	ret := (0xF << (4 * (i % 2)) & reach[i/2]) >> (4 * (i % 2))
-	return ret
+// Reach has a byte per cell holding the label of its component, so we
+// can have up to 255 components. Like Dist, it's a string so that it
+// works as a map key.
+type Reach string
+
+func GetReachValueAtIndex(reach Reach, i int) uint8 {
+	return reach[i]
 }
 
-func ReachToArray(reach [ReachSize]uint8) [BoardSize]uint8 {
-	var ret [BoardSize]uint8
-	for i := uint8(0); i < BoardSize; i++ {
+func ReachToArray(reach Reach) []uint8 {
+	ret := make([]uint8, Width)
+	for i := 0; i < Width; i++ {
 		ret[i] = GetReachValueAtIndex(reach, i)
 	}
 	return ret
 }
 
-func SetReachValueAtIndex(reach *[ReachSize]uint8, i uint8, val uint8) {
-	(*reach)[i/2] |= ((val) << (4 * (i % 2)))
+func SetReachValueAtIndex(reach []uint8, i int, val uint8) {
+	reach[i] = val
 }
 
-func ReachToString(reach [ReachSize]uint8) string {
+func ReachToString(reach Reach) string {
 	var b strings.Builder
-	for i := uint8(0); i < BoardSize; i++ {
+	for i := 0; i < len(reach); i++ {
 		fmt.Fprintf(&b, "%2d ", GetReachValueAtIndex(reach, i))
 	}
 	return b.String()
 }
 
-func GetMaxComponentInReach(reach [ReachSize]uint8) uint8 {
+func GetMaxComponentInReach(reach Reach) uint8 {
 	ret := uint8(0)
-	for i := uint8(0); i < BoardSize; i++ {
+	for i := 0; i < len(reach); i++ {
 		val := GetReachValueAtIndex(reach, i)
 		if val > ret {
 			ret = val
@@ -44,17 +49,51 @@ func GetMaxComponentInReach(reach [ReachSize]uint8) uint8 {
 	return ret
 }
 
-func ApplyReach(oldTopReach, oldBottomReach [ReachSize]uint8, oldTopRow, newTopRow uint32) (bool, [ReachSize]uint8, [ReachSize]uint8) {
-	// special edge case for all-black row, assume it always works
+// OpenComponents is how many components there are between reaches that
+// share their labels, like the top and bottom reach of a frontier
+func OpenComponents(reaches ...Reach) int {
+	seen := make(map[uint8]bool)
+	for _, reach := range reaches {
+		for i := 0; i < len(reach); i++ {
+			if val := GetReachValueAtIndex(reach, i); val > 0 {
+				seen[val] = true
+			}
+		}
+	}
+	return len(seen)
+}
+
+// CanHaveComponents says whether a board with closed components already
+// closed off can still end up with Components of them, where open says
+// whether there are any that aren't closed yet
+func CanHaveComponents(closed int, open bool) bool {
+	if open {
+		closed += 1
+	}
+	return Components == AnyComponents || closed <= Components
+}
+
+// HasComponents says whether a finished board with components pieces of
+// white squares has the right number of them. An all-black board
+// doesn't count
+func HasComponents(components int) bool {
+	return components > 0 && (Components == AnyComponents || components == Components)
+}
+
+// ApplyReach adds newTopRow on top of the frontier, and its opposite
+// under it. It returns how many components got closed off, since
+// nothing in the new rows touches them, along with the new reaches
+func ApplyReach(oldTopReach, oldBottomReach Reach, oldTopRow, newTopRow Row) (int, Reach, Reach) {
+	// special edge case for all-black row, which closes off everything
 	if newTopRow == MaxRow {
-		return true, RowToReachMap[MaxRow], RowToReachMap[MaxRow]
+		return OpenComponents(oldTopReach, oldBottomReach), RowToReach(MaxRow), RowToReach(MaxRow)
 	}
 
 	oldTopRowArray := RowToArray(oldTopRow)
-	oldBottomRowArray := RowToArray(RevMap[oldTopRow])
+	oldBottomRowArray := RowToArray(Opposite(oldTopRow))
 
 	newTopRowArray := RowToArray(newTopRow)
-	newBottomRowArray := RowToArray(RevMap[newTopRow])
+	newBottomRowArray := RowToArray(Opposite(newTopRow))
 
 	oldTopReachArray := ReachToArray(oldTopReach)
 	oldBottomReachArray := ReachToArray(oldBottomReach)
@@ -87,12 +126,12 @@ func ApplyReach(oldTopReach, oldBottomReach [ReachSize]uint8, oldTopRow, newTopR
 	}
 	sort.Slice(sortedKeys, func(i, j int) bool { return sortedKeys[i] < sortedKeys[j] })
 
-	// If a reach isn't connected to the new row from either side,
-	// return false because this means the grid is unconnected
+	// If a reach isn't connected to the new row from either side, it's
+	// closed off for good
+	closed := 0
 	for _, key := range sortedKeys {
 		if !connectedTop[key] && !connectedBottom[key] {
-			var dummy [ReachSize]uint8
-			return false, dummy, dummy
+			closed += 1
 		}
 	}
 
@@ -104,9 +143,9 @@ func ApplyReach(oldTopReach, oldBottomReach [ReachSize]uint8, oldTopRow, newTopR
 	currentComponent := uint8(0)
 	newComponentToComponentMap := make(map[uint8]uint8)
 
-	getFinalReach := func(row [BoardSize]bool, indexMap [BoardSize]uint8) [ReachSize]uint8 {
-		var ret [ReachSize]uint8
-		for i := uint8(0); i < BoardSize; i++ {
+	getFinalReach := func(row []bool, indexMap []uint8) Reach {
+		ret := make([]uint8, ReachSize)
+		for i := 0; i < Width; i++ {
 			// it's a white square, we care about its reach
 			if !row[i] {
 				// check if reachable from old row
@@ -115,53 +154,337 @@ func ApplyReach(oldTopReach, oldBottomReach [ReachSize]uint8, oldTopRow, newTopR
 					oldComponent := oldReachToComponentMap[oldReachValue]
 					newReachValue, exists := newComponentToComponentMap[oldComponent]
 					if exists {
-						SetReachValueAtIndex(&ret, i, newReachValue)
+						SetReachValueAtIndex(ret, i, newReachValue)
 					} else {
 						currentComponent += 1
 						newComponentToComponentMap[oldComponent] = currentComponent
-						SetReachValueAtIndex(&ret, i, currentComponent)
+						SetReachValueAtIndex(ret, i, currentComponent)
 					}
 				} else {
 					// this cell is not reachable from the previous row
 					if i == 0 {
 						// this must be component 1
 						currentComponent += 1
-						SetReachValueAtIndex(&ret, i, currentComponent)
+						SetReachValueAtIndex(ret, i, currentComponent)
 					} else {
-						prevReachValue := GetReachValueAtIndex(ret, i-1)
+						prevReachValue := GetReachValueAtIndex(Reach(ret), i-1)
 						if prevReachValue == 0 {
 							// previous is black, increment component
 							currentComponent += 1
-							SetReachValueAtIndex(&ret, i, currentComponent)
+							SetReachValueAtIndex(ret, i, currentComponent)
 						} else {
 							// previous is white, set same component
-							SetReachValueAtIndex(&ret, i, prevReachValue)
+							SetReachValueAtIndex(ret, i, prevReachValue)
 						}
 					}
 				}
 			}
 		}
-		return ret
+		return Reach(ret)
+	}
+
+	newTopReach := getFinalReach(newTopRowArray, newIndexMapTop)
+	newBottomReach := getFinalReach(newBottomRowArray, newIndexMapBottom)
+	if wrapsAcross() {
+		joined := joinAcross(newTopReach, newBottomReach)
+		newTopReach, newBottomReach = joined[0], joined[1]
+	}
+	return closed, newTopReach, newBottomReach
+}
+
+// ApplySingleReach is ApplyReach for boards built from the top, where
+// there's only the one frontier. Nothing can come back to a component
+// that isn't connected to the new row, so those get closed off.
+func ApplySingleReach(oldReach Reach, oldRow, newRow Row) (int, Reach) {
+	oldReachArray := ReachToArray(oldReach)
+	adjMap, newIndexMap, connected :=
+		applyReachNoRenumbering(
+			oldReachArray,
+			RowToArray(oldRow),
+			RowToArray(newRow),
+		)
+
+	var sortedKeys []uint8
+	for key, _ := range adjMap {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Slice(sortedKeys, func(i, j int) bool { return sortedKeys[i] < sortedKeys[j] })
+	closed := 0
+	for _, key := range sortedKeys {
+		if !connected[key] {
+			closed += 1
+		}
+	}
+	oldReachToComponentMap := getNewReachValueComponents(adjMap, nil, sortedKeys)
+
+	// number the new row in the order we see things, like ApplyReach
+	currentComponent := uint8(0)
+	componentMap := make(map[uint8]uint8)
+	ret := make([]uint8, ReachSize)
+	for i := 0; i < Width; i++ {
+		if newRow.Bit(i) {
+			continue
+		}
+		if newIndexMap[i] > 0 {
+			oldComponent := oldReachToComponentMap[newIndexMap[i]]
+			newReachValue, exists := componentMap[oldComponent]
+			if !exists {
+				currentComponent += 1
+				newReachValue = currentComponent
+				componentMap[oldComponent] = newReachValue
+			}
+			SetReachValueAtIndex(ret, i, newReachValue)
+		} else if i > 0 && ret[i-1] > 0 {
+			// carries on from the white square to the left
+			SetReachValueAtIndex(ret, i, ret[i-1])
+		} else {
+			currentComponent += 1
+			SetReachValueAtIndex(ret, i, currentComponent)
+		}
+	}
+	if wrapsAcross() {
+		return closed, joinAcross(Reach(ret))[0]
+	}
+	return closed, Reach(ret)
+}
+
+// InitPairReach gets the top and bottom reach for the middle of a board
+// with an even height, where row sits right on top of Opposite(row)
+func InitPairReach(row Row) (Reach, Reach) {
+	rowArrays := [2][]bool{RowToArray(row), RowToArray(Opposite(row))}
+
+	// union the white cells of the two rows, cell (r, i) is r*Width + i
+	parent := make([]int, 2*Width)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(x int) int
+	find = func(x int) int {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	for r := 0; r < 2; r++ {
+		for i := 0; i < Width; i++ {
+			if rowArrays[r][i] {
+				continue
+			}
+			if i > 0 && !rowArrays[r][i-1] {
+				parent[find(r*Width+i)] = find(r*Width + i - 1)
+			}
+			if r == 1 && !rowArrays[0][i] {
+				parent[find(Width+i)] = find(i)
+			}
+		}
+	}
+
+	// number components in the order we see them, top row first, the
+	// same way ApplyReach does
+	currentComponent := uint8(0)
+	componentMap := make(map[int]uint8)
+	var ret [2][]uint8
+	for r := 0; r < 2; r++ {
+		ret[r] = make([]uint8, ReachSize)
+		for i := 0; i < Width; i++ {
+			if rowArrays[r][i] {
+				continue
+			}
+			root := find(r*Width + i)
+			if _, exists := componentMap[root]; !exists {
+				currentComponent += 1
+				componentMap[root] = currentComponent
+			}
+			SetReachValueAtIndex(ret[r], i, componentMap[root])
+		}
+	}
+	if wrapsAcross() {
+		joined := joinAcross(Reach(ret[0]), Reach(ret[1]))
+		return joined[0], joined[1]
 	}
+	return Reach(ret[0]), Reach(ret[1])
+}
 
-	return true, getFinalReach(newTopRowArray, newIndexMapTop), getFinalReach(newBottomRowArray, newIndexMapBottom)
+// InitSplitReach gets the top and bottom reach for row and Opposite(row)
+// with nothing but black squares between them, which can only connect
+// up by wrapping around a torus
+func InitSplitReach(row Row) (Reach, Reach) {
+	top := []byte(RowToReach(row))
+	bottom := []byte(RowToReach(Opposite(row)))
+	offset := GetMaxComponentInReach(Reach(top))
+	for i := range bottom {
+		if bottom[i] > 0 {
+			bottom[i] += offset
+		}
+	}
+	return Reach(top), Reach(bottom)
+}
+
+// ApplyTorusReach is ApplySingleReach for a torus, where the last row
+// wraps around to touch the first one. firstReach labels the first row
+// with the same components as oldReach, so a component that reaches the
+// first row can still connect up at the end, even if it doesn't go on
+// to the new row. It returns how many components got closed off, the new
+// reach and firstReach.
+func ApplyTorusReach(oldReach, firstReach Reach, oldRow, newRow Row) (int, Reach, Reach) {
+	// union everything, where old components are 1 through
+	// maxComponent and square i of the new row is maxComponent+1+i
+	maxComponent := int(GetMaxComponentInReach(oldReach))
+	if other := int(GetMaxComponentInReach(firstReach)); other > maxComponent {
+		maxComponent = other
+	}
+	parent := make([]int, maxComponent+1+Width)
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(x int) int
+	find = func(x int) int {
+		if parent[x] != x {
+			parent[x] = find(parent[x])
+		}
+		return parent[x]
+	}
+	cell := func(i int) int {
+		return maxComponent + 1 + i
+	}
+	connected := make([]bool, maxComponent+1)
+	for i := 0; i < Width; i++ {
+		if newRow.Bit(i) {
+			continue
+		}
+		if old := GetReachValueAtIndex(oldReach, i); old > 0 {
+			parent[find(cell(i))] = find(int(old))
+			connected[old] = true
+		}
+		if next := (i + 1) % Width; !newRow.Bit(next) && (next > 0 || wrapsAcross()) {
+			parent[find(cell(next))] = find(cell(i))
+		}
+	}
+	closedOff := make(map[uint8]bool)
+	for i := 0; i < Width; i++ {
+		old := GetReachValueAtIndex(oldReach, i)
+		if old > 0 && !connected[old] && strings.IndexByte(string(firstReach), old) < 0 {
+			closedOff[old] = true
+		}
+	}
+
+	// number the new row first, then the first row, like ApplyReach
+	currentComponent := uint8(0)
+	componentMap := make(map[int]uint8)
+	label := func(x int) uint8 {
+		root := find(x)
+		if _, exists := componentMap[root]; !exists {
+			currentComponent += 1
+			componentMap[root] = currentComponent
+		}
+		return componentMap[root]
+	}
+	newReach := make([]uint8, ReachSize)
+	newFirstReach := make([]uint8, ReachSize)
+	for i := 0; i < Width; i++ {
+		if !newRow.Bit(i) {
+			SetReachValueAtIndex(newReach, i, label(cell(i)))
+		}
+	}
+	for i := 0; i < Width; i++ {
+		if first := GetReachValueAtIndex(firstReach, i); first > 0 {
+			SetReachValueAtIndex(newFirstReach, i, label(int(first)))
+		}
+	}
+	return len(closedOff), Reach(newReach), Reach(newFirstReach)
+}
+
+// TorusComponents is how many components the white squares in two
+// reaches of rows that touch by wrapping around a torus, like the top
+// and bottom rows, make once they get joined up
+func TorusComponents(a, b Reach) int {
+	parent := make(map[uint8]uint8)
+	var find func(x uint8) uint8
+	find = func(x uint8) uint8 {
+		if p, exists := parent[x]; exists && p != x {
+			root := find(p)
+			parent[x] = root
+			return root
+		}
+		return x
+	}
+	for i := 0; i < Width; i++ {
+		for _, reach := range []Reach{a, b} {
+			if val := GetReachValueAtIndex(reach, i); val > 0 {
+				if _, exists := parent[val]; !exists {
+					parent[val] = val
+				}
+			}
+		}
+		if a[i] > 0 && b[i] > 0 {
+			parent[find(a[i])] = find(b[i])
+		}
+	}
+	roots := 0
+	for val := range parent {
+		if find(val) == val {
+			roots += 1
+		}
+	}
+	return roots
 }
 
 // private helpers
 
+// joinAcross merges the components at the two ends of each reach, since
+// those squares touch when rows wrap around, then numbers everything
+// again in the order it shows up. The reaches all share their labels
+func joinAcross(reaches ...Reach) []Reach {
+	parent := make(map[uint8]uint8)
+	var find func(x uint8) uint8
+	find = func(x uint8) uint8 {
+		if p, exists := parent[x]; exists && p != x {
+			root := find(p)
+			parent[x] = root
+			return root
+		}
+		return x
+	}
+	for _, reach := range reaches {
+		left := GetReachValueAtIndex(reach, 0)
+		right := GetReachValueAtIndex(reach, Width-1)
+		if left > 0 && right > 0 && find(left) != find(right) {
+			parent[find(right)] = find(left)
+		}
+	}
+	componentMap := make(map[uint8]uint8)
+	ret := make([]Reach, len(reaches))
+	for r, reach := range reaches {
+		arr := make([]uint8, ReachSize)
+		for i := 0; i < Width; i++ {
+			val := GetReachValueAtIndex(reach, i)
+			if val == 0 {
+				continue
+			}
+			root := find(val)
+			if _, exists := componentMap[root]; !exists {
+				componentMap[root] = uint8(len(componentMap) + 1)
+			}
+			SetReachValueAtIndex(arr, i, componentMap[root])
+		}
+		ret[r] = Reach(arr)
+	}
+	return ret
+}
+
 func applyReachNoRenumbering(
-	oldReachArray [BoardSize]uint8,
-	oldRowArray [BoardSize]bool,
-	newRowArray [BoardSize]bool,
-) (map[uint8]map[uint8]bool, [BoardSize]uint8, map[uint8]bool) {
-	var grid [2][BoardSize]bool
+	oldReachArray []uint8,
+	oldRowArray []bool,
+	newRowArray []bool,
+) (map[uint8]map[uint8]bool, []uint8, map[uint8]bool) {
+	var grid [2][]bool
 	connected := make(map[uint8]bool)
 	grid[0] = oldRowArray
 	grid[1] = newRowArray
 
 	// get a map of reach to indices it contains from old row
-	oldReachValueToIndicesMap := make(map[uint8][]uint8)
-	for i := uint8(0); i < BoardSize; i++ {
+	oldReachValueToIndicesMap := make(map[uint8][]int)
+	for i := 0; i < Width; i++ {
 		// if reach is zero, it's a black square
 		if oldReachArray[i] > 0 {
 			cur := oldReachValueToIndicesMap[oldReachArray[i]]
@@ -170,11 +493,11 @@ func applyReachNoRenumbering(
 	}
 
 	// lets do it
-	var newRowIndexToOldReachValue [BoardSize]uint8
+	newRowIndexToOldReachValue := make([]uint8, Width)
 	oldReachValueAdjacencyMap := make(map[uint8]map[uint8]bool)
 
 	for oldReachValue, indicesInOldReachValue := range oldReachValueToIndicesMap {
-		var visited [2][BoardSize]bool
+		visited := [2][]bool{make([]bool, Width), make([]bool, Width)}
 		oldReachValueAdjacencyMap[oldReachValue] = make(map[uint8]bool)
 
 		// check if this component is adjacent to a white square
@@ -199,7 +522,7 @@ func applyReachNoRenumbering(
 			count += 1
 			cur := queue[0]
 			queue = queue[1:]
-			row, index := cur/BoardSize, cur%BoardSize
+			row, index := cur/Width, cur%Width
 			visited[row][index] = true
 
 			existingOldReachValue := newRowIndexToOldReachValue[index]
@@ -217,9 +540,9 @@ func applyReachNoRenumbering(
 
 			// add unvisited neighbors values to the queue
 			// because i don't want to store tuples, we map
-			// (i, j) --> BoardSize * i + j
+			// (i, j) --> Width * i + j
 			if !visited[1-row][index] && !grid[1-row][index] {
-				queue = append(queue, BoardSize*(1-row)+index)
+				queue = append(queue, Width*(1-row)+index)
 			}
 			if index > 0 {
 				if !grid[row][index-1] {
@@ -228,7 +551,7 @@ func applyReachNoRenumbering(
 					}
 				}
 			}
-			if index < BoardSize-1 {
+			if index < Width-1 {
 				if !visited[row][index+1] && !grid[row][index+1] {
 					queue = append(queue, cur+1)
 				}
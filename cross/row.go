@@ -0,0 +1,101 @@
+package cross
+
+import (
+	"math/bits"
+)
+
+// RowWords is how many uint64s make up a Row
+const RowWords = 2
+
+// Row is a bitmask of the black squares in a row, with the leftmost
+// square in the lowest bit of the first word. It's an array so that it
+// can still be compared and used as a map key.
+type Row [RowWords]uint64
+
+func (row Row) Bit(i int) bool {
+	return row[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (row Row) WithBit(i int) Row {
+	row[i/64] |= 1 << uint(i%64)
+	return row
+}
+
+func (row Row) And(other Row) Row {
+	for w := range row {
+		row[w] &= other[w]
+	}
+	return row
+}
+
+func (row Row) Or(other Row) Row {
+	for w := range row {
+		row[w] |= other[w]
+	}
+	return row
+}
+
+func (row Row) AndNot(other Row) Row {
+	for w := range row {
+		row[w] &^= other[w]
+	}
+	return row
+}
+
+func (row Row) IsZero() bool {
+	return row == Row{}
+}
+
+func (row Row) OnesCount() int {
+	count := 0
+	for _, word := range row {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// Less is an arbitrary but fixed order on rows, used to pick one of a
+// row and its reverse
+func (row Row) Less(other Row) bool {
+	for w := RowWords - 1; w >= 0; w-- {
+		if row[w] != other[w] {
+			return row[w] < other[w]
+		}
+	}
+	return false
+}
+
+// ReadingLess orders rows the way they'd be in as strings of # and ., so
+// the leftmost square counts the most and a black square comes before a
+// white one
+func (row Row) ReadingLess(other Row) bool {
+	for w := range row {
+		if diff := row[w] ^ other[w]; diff != 0 {
+			return row[w]&(diff&-diff) != 0
+		}
+	}
+	return false
+}
+
+// Reverse mirrors a row left to right
+func Reverse(row Row) Row {
+	var rev Row
+	for w := range row {
+		rev[RowWords-1-w] = bits.Reverse64(row[w])
+	}
+	// that leaves the row packed against the top of the last word, so
+	// move it back down to start at bit 0
+	return rev.shiftDown(RowWords*64 - Width)
+}
+
+func (row Row) shiftDown(n int) Row {
+	var ret Row
+	words, rem := n/64, uint(n%64)
+	for w := 0; w+words < RowWords; w++ {
+		ret[w] = row[w+words] >> rem
+		if rem != 0 && w+words+1 < RowWords {
+			ret[w] |= row[w+words+1] << (64 - rem)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,112 @@
+package cross
+
+import (
+	"fmt"
+	"github.com/golang-collections/go-datastructures/bitarray"
+	"strings"
+)
+
+// Runs keeps track of how long the white run at the top of each column
+// is, so we can cap down entries at MaxLength. It's one byte per column:
+// a column with a black square holds the number of white squares since
+// then, and open columns work like they do in Dist, where runsOpen means
+// the opposite column is open too and runsOpen+1+u means it closed after
+// u squares. It's empty when there's no cap.
+type Runs string
+
+func runsOpen() uint8 {
+	return uint8(MaxLength) + 1
+}
+
+func RunsToString(runs Runs) string {
+	var b strings.Builder
+	for i := 0; i < len(runs); i++ {
+		fmt.Fprintf(&b, "%2d ", runs[i])
+	}
+	return b.String()
+}
+
+// InitRuns is the runs before any rows have been added, where every
+// column is open, or right under the edge for boards built from the top
+func InitRuns() Runs {
+	if MaxLength == 0 {
+		return ""
+	}
+	ret := make([]uint8, Width)
+	if Folded() {
+		for i := range ret {
+			ret[i] = runsOpen()
+		}
+	}
+	return Runs(ret)
+}
+
+// GetPossibleNextRowsForRuns gets the rows that can go on top of runs as
+// row number index, counting out from the middle. These are the rows
+// with a black square wherever a column would run too long otherwise.
+func GetPossibleNextRowsForRuns(runs Runs, index int) bitarray.BitArray {
+	if MaxLength == 0 {
+		return AllRowsBitArray
+	}
+	var key Row
+	open := runsOpen()
+	for i := 0; i < Width; i++ {
+		length := 0
+		switch {
+		case runs[i] == open:
+			// best case, the opposite column closes right now
+			opposite := index
+			if oppositeColumn(i) == i {
+				opposite = index + 1
+			}
+			length = middleRunLength(index+1, opposite)
+		case runs[i] > open:
+			length = middleRunLength(index+1, int(runs[i]-open-1))
+		default:
+			length = int(runs[i]) + 1
+		}
+		if length > MaxLength {
+			key = key.WithBit(i)
+		}
+	}
+	return AvoidOneZero(key)
+}
+
+// ApplyRuns adds row on top of runs as row number index, counting out
+// from the middle. It returns false if a down run gets too long.
+func ApplyRuns(runs Runs, row Row, index int) (bool, Runs) {
+	if MaxLength == 0 {
+		return true, ""
+	}
+	ret := make([]uint8, Width)
+	open := runsOpen()
+	for i := 0; i < Width; i++ {
+		opposite := oppositeColumn(i)
+		if row.Bit(i) {
+			ret[i] = 0
+			continue
+		}
+
+		length := 0
+		switch {
+		case runs[i] == open && (opposite == i || !row.Bit(opposite)):
+			// both halves of the run are still going
+			length = middleRunLength(index+1, index+1)
+			ret[i] = open
+		case runs[i] == open:
+			// the opposite column closes now with index squares in it
+			length = middleRunLength(index+1, index)
+			ret[i] = open + 1 + uint8(index)
+		case runs[i] > open:
+			length = middleRunLength(index+1, int(runs[i]-open-1))
+			ret[i] = runs[i]
+		default:
+			length = int(runs[i]) + 1
+			ret[i] = uint8(length)
+		}
+		if length > MaxLength {
+			return false, ""
+		}
+	}
+	return true, Runs(ret)
+}
@@ -0,0 +1,105 @@
+package cross
+
+// SearchBoards calls f on every valid board with the current symmetry,
+// which is how StrategySearch works. It goes a row at a time from the
+// top, where any square the symmetry ties to a square in an earlier row
+// is already decided. Threads split up the work by the top row, so each
+// of them should call this with its own threadID.
+func SearchBoards(threadID, numThreads int, f func(board []Row)) {
+	elements := BoardSymmetry.Elements()
+	board := make([]Row, Height)
+
+	// make sure row r agrees with itself wherever the symmetry ties two
+	// of its squares together
+	consistent := func(r int, row Row) bool {
+		for j := 0; j < Width; j++ {
+			for _, e := range elements {
+				a, b := e.Apply(r, j)
+				if a == r && row.Bit(b) != row.Bit(j) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// runs has the length of the white run at the bottom of each column
+	// after the first r rows. On a torus, a run of r started at the top
+	// edge, so it can carry on from the bottom
+	var recurse func(r int, runs []int)
+	recurse = func(r int, runs []int) {
+		if r == Height {
+			if wrapsDown() {
+				if !HasValidRuns(board) {
+					return
+				}
+			} else {
+				for _, run := range runs {
+					if !isValidLength(run) {
+						return
+					}
+				}
+			}
+			if !HasComponents(WhiteComponents(board)) {
+				return
+			}
+			if TracksBlackRegions() && !HasBlackRegions(BoardBlackRegions(board)) {
+				return
+			}
+			if Biconnected && len(ArticulationCells(board)) > 0 {
+				return
+			}
+			f(board)
+			return
+		}
+
+		mustWhite := FixedWhite[r]
+		mustBlack := Mask[r].Or(FixedBlack[r])
+		for j := 0; j < Width; j++ {
+			for _, e := range elements {
+				a, b := e.Apply(r, j)
+				if a < r {
+					if board[a].Bit(b) {
+						mustBlack = mustBlack.WithBit(j)
+					} else {
+						mustWhite = mustWhite.WithBit(j)
+					}
+				}
+			}
+			switch {
+			case runs[j] > 0 && runs[j] < MinLength && !(wrapsDown() && runs[j] == r):
+				mustWhite = mustWhite.WithBit(j)
+			case MaxLength != 0 && runs[j] == MaxLength:
+				mustBlack = mustBlack.WithBit(j)
+			case runs[j] == 0 && Height-r < MinLength && !wrapsDown():
+				// not enough room left for a new run
+				mustBlack = mustBlack.WithBit(j)
+			}
+		}
+		if !mustWhite.And(mustBlack).IsZero() {
+			return
+		}
+
+		possRows := ToRows(AvoidOneOne(mustWhite).And(AvoidOneZero(mustBlack)))
+		if mustWhite.IsZero() {
+			possRows = append(possRows, MaxRow)
+		}
+		for idx, row := range possRows {
+			if r == 0 && idx%numThreads != threadID {
+				continue
+			}
+			if !consistent(r, row) {
+				continue
+			}
+			board[r] = row
+			nextRuns := make([]int, Width)
+			for j := 0; j < Width; j++ {
+				if !row.Bit(j) {
+					nextRuns[j] = runs[j] + 1
+				}
+			}
+			recurse(r+1, nextRuns)
+		}
+	}
+	recurse(0, make([]int, Width))
+}
@@ -0,0 +1,128 @@
+package cross
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mask has the squares outside the board's shape for each row, in the
+// same orientation as Width and Height. Rows store them as black
+// squares, since that's how they work for runs and connectivity, so the
+// real black squares of a row are row.AndNot(Mask[i]). Without a shape
+// it's all zero.
+var Mask []Row
+
+// MirrorPairs says whether the left-right mirror of a board is always
+// another board, so the DP can count boards in pairs. Init works it out
+// once everything that could tell a board from its mirror is set up.
+var MirrorPairs bool
+
+// ParseShape reads a shape with a line per row, where '.' is a square
+// outside the shape and anything else is inside it. It comes back with
+// true for the squares outside, ready for Config.Shape
+func ParseShape(s string) ([][]bool, error) {
+	var shape [][]bool
+	for _, line := range strings.Split(strings.TrimRight(s, "\r\n"), "\n") {
+		line = strings.TrimRight(line, "\r")
+		row := make([]bool, len(line))
+		for j, c := range line {
+			row[j] = c == '.'
+		}
+		if len(shape) > 0 && len(row) != len(shape[0]) {
+			return nil, fmt.Errorf("every row of a shape needs the same length, row %v has %v not %v", len(shape), len(row), len(shape[0]))
+		}
+		shape = append(shape, row)
+	}
+	return shape, nil
+}
+
+// initMask fills in Mask from a shape the way Config has it, which still
+// needs transposing if the board does
+func initMask(shape [][]bool) error {
+	Mask = make([]Row, Height)
+	if shape == nil {
+		return nil
+	}
+	outside := func(i, j int) bool {
+		if Transposed {
+			return shape[j][i]
+		}
+		return shape[i][j]
+	}
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			if outside(i, j) {
+				Mask[i] = Mask[i].WithBit(j)
+			}
+		}
+		if Mask[i] == MaxRow {
+			return fmt.Errorf("shape has an empty row or column, trim it off")
+		}
+	}
+	allColumns := MaxRow
+	for _, row := range Mask {
+		allColumns = allColumns.And(row)
+	}
+	if !allColumns.IsZero() {
+		return fmt.Errorf("shape has an empty row or column, trim it off")
+	}
+
+	// the shape has to have the board's symmetry, or there's no boards
+	for _, e := range BoardSymmetry.Elements() {
+		if !keepsMask(e) {
+			if Transposed {
+				e = e.Transpose()
+			}
+			return fmt.Errorf("shape doesn't have symmetry %v", e)
+		}
+	}
+	return nil
+}
+
+// HasShape says whether the board has a shape instead of being a whole
+// rectangle
+func HasShape() bool {
+	for _, row := range Mask {
+		if !row.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepsShape checks that the single transformation g, on the board the
+// way it was asked for, sends the shape to itself
+func KeepsShape(g Symmetry) bool {
+	if Transposed {
+		g = g.Transpose()
+	}
+	return keepsMask(g)
+}
+
+// keepsMask is KeepsShape for a transformation of the board as it's
+// stored, after any transposing
+func keepsMask(g Symmetry) bool {
+	for i := 0; i < Height; i++ {
+		for j := 0; j < Width; j++ {
+			a, b := g.Apply(i, j)
+			if Mask[i].Bit(j) != Mask[a].Bit(b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// DPRow gets which row of the board the DP adds as row number index
+func DPRow(index int) int {
+	if Folded() {
+		return HalfHeight - 1 - index
+	}
+	return index
+}
+
+// FitsMask checks that row has the squares outside the shape where row i
+// of the board needs them
+func FitsMask(row Row, i int) bool {
+	return row.And(Mask[i]) == Mask[i]
+}
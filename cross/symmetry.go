@@ -0,0 +1,276 @@
+package cross
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Symmetry is a set of transformations that every board has to look the
+// same under. The zero value means no symmetry at all.
+type Symmetry uint8
+
+const (
+	// Rot90 turns the board a quarter turn clockwise
+	Rot90 Symmetry = 1 << iota
+	Rot180
+	Rot270
+	// MirrorLR flips the board left to right
+	MirrorLR
+	// MirrorTB flips the board top to bottom
+	MirrorTB
+	// Diagonal flips the board over the top left to bottom right diagonal
+	Diagonal
+	// AntiDiagonal flips the board over the top right to bottom left
+	// diagonal
+	AntiDiagonal
+)
+
+var symmetryNames = []struct {
+	sym  Symmetry
+	name string
+}{
+	{Rot90, "rot90"},
+	{Rot180, "rot180"},
+	{Rot270, "rot270"},
+	{MirrorLR, "lr"},
+	{MirrorTB, "tb"},
+	{Diagonal, "diag"},
+	{AntiDiagonal, "antidiag"},
+}
+
+// where each transformation sends the corners of a square, numbered
+// clockwise from the top left. That's enough to tell them apart and to
+// compose them
+var symmetryCorners = map[Symmetry][4]int{
+	0:            {0, 1, 2, 3},
+	Rot90:        {1, 2, 3, 0},
+	Rot180:       {2, 3, 0, 1},
+	Rot270:       {3, 0, 1, 2},
+	MirrorLR:     {1, 0, 3, 2},
+	MirrorTB:     {3, 2, 1, 0},
+	Diagonal:     {0, 3, 2, 1},
+	AntiDiagonal: {2, 1, 0, 3},
+}
+
+// ParseSymmetry reads a comma separated list of symmetry names like
+// "rot180,lr", or "none"
+func ParseSymmetry(s string) (Symmetry, error) {
+	var ret Symmetry
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "none" || part == "" {
+			continue
+		}
+		found := false
+		for _, sn := range symmetryNames {
+			if sn.name == part {
+				ret |= sn.sym
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown symmetry %q", part)
+		}
+	}
+	return ret, nil
+}
+
+func (s Symmetry) String() string {
+	var names []string
+	for _, sn := range symmetryNames {
+		if s&sn.sym != 0 {
+			names = append(names, sn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// Elements lists every transformation in s one at a time
+func (s Symmetry) Elements() []Symmetry {
+	var ret []Symmetry
+	for _, sn := range symmetryNames {
+		if s&sn.sym != 0 {
+			ret = append(ret, sn.sym)
+		}
+	}
+	return ret
+}
+
+// compose gets the single transformation that does a and then b
+func compose(a, b Symmetry) Symmetry {
+	var corners [4]int
+	for c := 0; c < 4; c++ {
+		corners[c] = symmetryCorners[b][symmetryCorners[a][c]]
+	}
+	for sym, symCorners := range symmetryCorners {
+		if symCorners == corners {
+			return sym
+		}
+	}
+	panic("transformations of a square always compose")
+}
+
+// Closure adds everything you get by doing the transformations in s one
+// after another, since a board with two symmetries has both combined too
+func (s Symmetry) Closure() Symmetry {
+	ret := s
+	for changed := true; changed; {
+		changed = false
+		for _, a := range ret.Elements() {
+			for _, b := range ret.Elements() {
+				if sym := compose(a, b); ret&sym != sym {
+					ret |= sym
+					changed = true
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// Equivalences lists the transformations of a width x height board that
+// take boards with symmetry s to other boards with symmetry s, 0 being
+// the identity. Those are the ones that make two such boards the same
+// up to rotation and reflection. It's every one of them unless s has a
+// mirror on a square board that a quarter turn would change.
+func (s Symmetry) Equivalences(width, height int) []Symmetry {
+	s = s.Closure()
+	candidates := []Symmetry{0, Rot180, MirrorLR, MirrorTB}
+	if width == height {
+		candidates = append(candidates, Rot90, Rot270, Diagonal, AntiDiagonal)
+	}
+	var ret []Symmetry
+	for _, g := range candidates {
+		// g undoes itself unless it's a quarter turn
+		inverse := g
+		if g == Rot90 || g == Rot270 {
+			inverse = (Rot90 | Rot270) ^ g
+		}
+		keeps := true
+		for _, h := range s.Elements() {
+			if s&compose(compose(inverse, h), g) == 0 {
+				keeps = false
+			}
+		}
+		if keeps {
+			ret = append(ret, g)
+		}
+	}
+	return ret
+}
+
+// Transpose gets the same symmetry as seen on the transposed board
+func (s Symmetry) Transpose() Symmetry {
+	ret := s &^ (Rot90 | Rot270 | MirrorLR | MirrorTB)
+	swap := func(a, b Symmetry) {
+		if s&a != 0 {
+			ret |= b
+		}
+		if s&b != 0 {
+			ret |= a
+		}
+	}
+	swap(Rot90, Rot270)
+	swap(MirrorLR, MirrorTB)
+	return ret
+}
+
+// Searched says whether boards with symmetry s get found one at a time
+// by SearchBoards instead of counted by the DP
+func (s Symmetry) Searched() bool {
+	return strategyFor(s) == StrategySearch
+}
+
+// Apply sends square (i, j) of the board to where the single
+// transformation s puts it. The ones that turn the board sideways only
+// make sense on square boards.
+func (s Symmetry) Apply(i, j int) (int, int) {
+	return s.applyIn(i, j, Width, Height)
+}
+
+// applyIn is Apply for a width x height block of squares. Turning it
+// sideways makes it height x width
+func (s Symmetry) applyIn(i, j, width, height int) (int, int) {
+	switch s {
+	case Rot90:
+		return j, height - 1 - i
+	case Rot180:
+		return height - 1 - i, width - 1 - j
+	case Rot270:
+		return width - 1 - j, i
+	case MirrorLR:
+		return i, width - 1 - j
+	case MirrorTB:
+		return height - 1 - i, j
+	case Diagonal:
+		return j, i
+	case AntiDiagonal:
+		return width - 1 - j, height - 1 - i
+	}
+	return i, j
+}
+
+// Strategy is how boards with the current symmetry get built
+type Strategy int
+
+const (
+	// StrategySingle builds the board a row at a time from the top
+	StrategySingle Strategy = iota
+	// StrategyRotate builds out from the middle, where the bottom half
+	// is the top half turned around
+	StrategyRotate
+	// StrategyFlip builds out from the middle, where the bottom half is
+	// the top half upside down
+	StrategyFlip
+	// StrategySearch is for symmetries that mix up rows and columns,
+	// which rows can't keep track of. It searches every board instead
+	StrategySearch
+)
+
+func strategyFor(sym Symmetry) Strategy {
+	switch {
+	case sym&(Rot90|Diagonal|AntiDiagonal) != 0:
+		return StrategySearch
+	case sym&Rot180 != 0:
+		return StrategyRotate
+	case sym&MirrorTB != 0:
+		return StrategyFlip
+	}
+	return StrategySingle
+}
+
+// Opposite gets the row in the bottom half that mirrors row in the top
+// half, for the strategies that build out from the middle
+func Opposite(row Row) Row {
+	if BoardStrategy == StrategyRotate {
+		return Reverse(row)
+	}
+	return row
+}
+
+// oppositeColumn is the column that Opposite moves column i to
+func oppositeColumn(i int) int {
+	if BoardStrategy == StrategyRotate {
+		return Width - 1 - i
+	}
+	return i
+}
+
+// Folded says whether the DP builds out from the middle, so it only
+// picks the top HalfHeight rows
+func Folded() bool {
+	return BoardStrategy == StrategyRotate || BoardStrategy == StrategyFlip
+}
+
+// Copies is how many rows of the board row number index stands for,
+// counting out from the middle. Building out from the middle, that's the
+// row and its opposite, unless it's the middle row of an odd board
+func Copies(index int) int {
+	if Folded() && (index > 0 || Height%2 == 0) {
+		return 2
+	}
+	return 1
+}
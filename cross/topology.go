@@ -0,0 +1,51 @@
+package cross
+
+import (
+	"fmt"
+)
+
+// Topology says which edges of the board wrap around to the opposite
+// edge, so entries can run off one side and carry on from the other
+type Topology int
+
+const (
+	// Flat is the usual board, where every edge is an edge
+	Flat Topology = iota
+	// Cylinder joins the left edge to the right one, so across entries
+	// can wrap around
+	Cylinder
+	// Torus joins the top and bottom edges too
+	Torus
+)
+
+var topologyNames = []string{"flat", "cylinder", "torus"}
+
+// ParseTopology reads one of "flat", "cylinder" or "torus"
+func ParseTopology(s string) (Topology, error) {
+	for t, name := range topologyNames {
+		if name == s {
+			return Topology(t), nil
+		}
+	}
+	return Flat, fmt.Errorf("unknown topology %q", s)
+}
+
+func (t Topology) String() string {
+	return topologyNames[t]
+}
+
+// Keeps says whether transformation g takes the topology to itself. A
+// cylinder only wraps one way, so it can't be turned sideways
+func (t Topology) Keeps(g Symmetry) bool {
+	return t != Cylinder || g&(Rot90|Rot270|Diagonal|AntiDiagonal) == 0
+}
+
+// wrapsAcross says whether squares at the two ends of a row touch
+func wrapsAcross() bool {
+	return BoardTopology != Flat
+}
+
+// wrapsDown says whether squares at the two ends of a column touch
+func wrapsDown() bool {
+	return BoardTopology == Torus
+}
@@ -0,0 +1,108 @@
+package cross
+
+// TopRuns keeps the length of the white run at the top of each column,
+// for a torus built from the top, where that run carries on from the
+// bottom row. It's one byte per column, and topRunsOpen means the column
+// has been white all the way down so far. Lengths past what the limits
+// care about are all the same, so they stop at topRunsOpen-1. It's empty
+// for any other board.
+type TopRuns string
+
+func topRunsOpen() uint8 {
+	if MaxLength > MinLength {
+		return uint8(MaxLength) + 1
+	}
+	return uint8(MinLength) + 1
+}
+
+// InitTopRuns is the top runs before any rows have been added
+func InitTopRuns() TopRuns {
+	if Folded() || !wrapsDown() {
+		return ""
+	}
+	ret := make([]uint8, Width)
+	for i := range ret {
+		ret[i] = topRunsOpen()
+	}
+	return TopRuns(ret)
+}
+
+// ApplyTopRuns adds row under topRuns as row number index, which closes
+// off the top run of any column it has a black square in
+func ApplyTopRuns(topRuns TopRuns, row Row, index int) TopRuns {
+	if topRuns == "" {
+		return ""
+	}
+	ret := []uint8(topRuns)
+	open := topRunsOpen()
+	for i := 0; i < Width; i++ {
+		if ret[i] == open && row.Bit(i) {
+			ret[i] = open - 1
+			if index < int(open-1) {
+				ret[i] = uint8(index)
+			}
+		}
+	}
+	return TopRuns(ret)
+}
+
+// IsValidTorus is IsValidDist for a torus, once the top row is in. The
+// run at the top of each column carries on from the one at the bottom,
+// so together they have to make a valid entry. For boards built out from
+// the middle, the bottom of a column is the top of its opposite column,
+// and otherwise topRuns knows how the top of each column started.
+func IsValidTorus(dist Dist, runs Runs, topRuns TopRuns) bool {
+	open := distOpen()
+	// endLength is how long the run since the last black square of a
+	// closed column is, which dist only knows up to MinLength
+	endLength := func(i int) int {
+		return MinLength - int(GetDistAtIndex(dist, i))
+	}
+	for i := 0; i < Width; i++ {
+		curDist := GetDistAtIndex(dist, i)
+		// length only matters up to MinLength, but exact is what
+		// MaxLength gets checked against
+		length, exact := 0, 0
+		if !Folded() {
+			if topRuns[i] == topRunsOpen() {
+				// white all the way around, ApplyRuns already checked
+				// MaxLength
+				length = Height
+			} else {
+				length = int(topRuns[i]) + endLength(i)
+				if MaxLength != 0 {
+					exact = int(topRuns[i]) + int(runs[i])
+				}
+			}
+		} else {
+			opposite := oppositeColumn(i)
+			switch {
+			case curDist == open:
+				length = middleRunLength(HalfHeight, HalfHeight)
+			case curDist > open:
+				// the run through the middle goes up over the top and
+				// comes back up the bottom of the column, which is the top
+				// of the opposite column upside down
+				u := int(curDist - open - 1)
+				length = middleRunLength(HalfHeight, u) + endLength(opposite)
+				if MaxLength != 0 {
+					u = int(runs[i] - runsOpen() - 1)
+					exact = middleRunLength(HalfHeight, u) + int(runs[opposite])
+				}
+			case GetDistAtIndex(dist, opposite) < open:
+				length = endLength(i) + endLength(opposite)
+				if MaxLength != 0 {
+					exact = int(runs[i]) + int(runs[opposite])
+				}
+			default:
+				// the opposite column has the same run upside down, so it
+				// gets checked there
+				continue
+			}
+		}
+		if !isValidLength(length) || MaxLength != 0 && exact > MaxLength {
+			return false
+		}
+	}
+	return true
+}
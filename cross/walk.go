@@ -0,0 +1,127 @@
+package cross
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+)
+
+// SampleBoard picks one of the boards lay has uniformly at random. It
+// goes a row at a time with each row as likely as the share of the boards
+// that go on with it. It comes back the way BoardToPattern has it, or nil
+// if there aren't any boards.
+func SampleBoard(lay *Layers, rng *rand.Rand) Pattern {
+	rows := lay.walk(func(index int, choices []choice) int {
+		total := new(big.Int)
+		for _, c := range choices {
+			total.Add(total, c.n)
+		}
+		if total.Sign() == 0 {
+			return -1
+		}
+		return nth(choices, new(big.Int).Rand(rng, total))
+	})
+	if rows == nil {
+		return nil
+	}
+	return BoardToPattern(fullBoard(rows))
+}
+
+// A board's rank is where it is among the boards lay has, in the order
+// they'd be in as strings the way BoardToPattern has them, top row first,
+// so a black square comes before a white one. It goes from 0 up to one
+// less than how many boards there are. Going through them in that order
+// means going back through every layer of the DP for a folded board,
+// which takes about as long as counting them. A board that's wider than
+// it's tall needs Config.ReadingOrder, or the DP has it sideways.
+
+// UnrankBoard gets the board lay has with the given rank. It comes back
+// the way BoardToPattern has it, and it's an error if there aren't that
+// many boards.
+func UnrankBoard(lay *Layers, rank *big.Int) (Pattern, error) {
+	if Transposed {
+		return nil, fmt.Errorf("ranking a board wider than it's tall needs Config.ReadingOrder")
+	}
+	var rows []Row
+	if rank.Sign() >= 0 {
+		n := new(big.Int).Set(rank)
+		rows = lay.walkInOrder(func(index int, choices []choice) int {
+			return nth(choices, n)
+		})
+	}
+	if rows == nil {
+		return nil, fmt.Errorf("rank %v isn't between 0 and the number of boards", rank)
+	}
+	return BoardToPattern(fullBoard(rows)), nil
+}
+
+// RankBoard gets the rank of board among the ones lay has. The board has
+// every square filled in, the way BoardToPattern has it. The rank is nil
+// if it isn't one of the boards.
+func RankBoard(lay *Layers, board Pattern) (*big.Int, error) {
+	if Transposed {
+		return nil, fmt.Errorf("ranking a board wider than it's tall needs Config.ReadingOrder")
+	}
+	full, err := PatternToBoard(board)
+	if err != nil {
+		return nil, err
+	}
+	rank := new(big.Int)
+	rows := lay.walkInOrder(func(index int, choices []choice) int {
+		for k, c := range choices {
+			if startsWith(full, c.rows, index) {
+				return k
+			}
+			// it comes after every board that goes this way
+			rank.Add(rank, c.n)
+		}
+		return -1
+	})
+	if rows == nil {
+		return nil, nil
+	}
+	// the walk only looked at the rows the DP adds, so the other half of
+	// a folded board could still be different
+	for i, row := range fullBoard(rows) {
+		if row != full[i] {
+			return nil, nil
+		}
+	}
+	return rank, nil
+}
+
+// startsWith checks that board has rows as row number index on up
+func startsWith(board, rows []Row, index int) bool {
+	for k, row := range rows {
+		if index+k >= HalfHeight || board[DPRow(index+k)] != row {
+			return false
+		}
+	}
+	return true
+}
+
+// nth picks the choice the nth board goes through, counting from 0 in
+// the order the choices are in, and takes the boards in the choices
+// before it off n
+func nth(choices []choice, n *big.Int) int {
+	for k, c := range choices {
+		if n.Cmp(c.n) < 0 {
+			return k
+		}
+		n.Sub(n, c.n)
+	}
+	return -1
+}
+
+// fullBoard gets every row of the board a walk got to, from the top down
+func fullBoard(rows []Row) []Row {
+	board := make([]Row, Height)
+	for index, row := range rows {
+		i := DPRow(index)
+		board[i] = row
+		if Folded() {
+			board[Height-1-i] = Opposite(row)
+		}
+	}
+	return board
+}
@@ -0,0 +1,65 @@
+package cross
+
+// Every white run is an entry, since the limits on entry lengths already
+// rule out the runs that would be too short or too long. So counting
+// words just means counting the squares that start a run.
+
+// RowEntries is how many across entries row has. A row that wraps around
+// and is white all the way across still has one
+func RowEntries(row Row) int {
+	count := 0
+	for i := 0; i < Width; i++ {
+		if row.Bit(i) {
+			continue
+		}
+		if i > 0 && row.Bit(i-1) || i == 0 && (!wrapsAcross() || row.Bit(Width-1)) {
+			count++
+		}
+	}
+	if wrapsAcross() && row.IsZero() {
+		count++
+	}
+	return count
+}
+
+// EntryStarts is how many down entries start in row, which are its white
+// squares under a black square in above. Pass MaxRow for above at the
+// top edge, since the edge works like a black square
+func EntryStarts(above, row Row) int {
+	return above.AndNot(row).OnesCount()
+}
+
+// TorusTopStarts is how many down entries start in the top row of a
+// torus, where the row above it is the bottom row. lastRow is the last
+// row the DP added. A column that's white all the way around has an entry
+// with no start, so it gets counted here too
+func TorusTopStarts(lastRow Row, dist Dist, topRuns TopRuns) int {
+	count := 0
+	if Folded() {
+		// the top row is lastRow, and the bottom row is its opposite
+		count = EntryStarts(Opposite(lastRow), lastRow)
+		for i := 0; i < Width; i++ {
+			if GetDistAtIndex(dist, i) == distOpen() {
+				count++
+			}
+		}
+		return count
+	}
+	// lastRow is the bottom row, and topRuns[i] is 0 when the top row is
+	// black in column i
+	for i := 0; i < Width; i++ {
+		if topRuns[i] == topRunsOpen() || topRuns[i] != 0 && lastRow.Bit(i) {
+			count++
+		}
+	}
+	return count
+}
+
+// BoardEntries is how many entries there are in a whole board
+func BoardEntries(board []Row) int {
+	count := 0
+	forEachBoardRun(board, func(run int) {
+		count++
+	})
+	return count
+}
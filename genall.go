@@ -2,6 +2,7 @@ package main
 
 import (
 	. "./cross"
+	"flag"
 	"fmt"
 	"os"
 	"runtime/pprof"
@@ -10,172 +11,401 @@ import (
 	"time"
 )
 
-const Profile bool = true
 const NumThreads int = 6
 const LogFrequency uint64 = 100000000
 
-func isConnected(board [HalfBoardSize]uint32, grid, visited *[BoardSize][BoardSize]bool) bool {
-	for i := 0; i < BoardSize; i++ {
-		if i < HalfBoardSize {
-			grid[i] = RowToArray(board[i])
-		} else {
-			grid[i] = RowToArray(RevMap[board[BoardSize-1-i]])
-		}
+// bounds has the limits on black squares and words
+var bounds Bounds
+
+// maxCheaters is the most cheaters a board can have, or -1 for no limit
+var maxCheaters int
+
+func main() {
+	from := flag.Int("from", 21, "smallest board size to enumerate")
+	to := flag.Int("to", 0, "largest board size to enumerate, defaults to -from")
+	step := flag.Int("step", 2, "gap between board sizes in the sweep, 1 includes the even sizes")
+	width := flag.Int("width", 0, "board width, to enumerate one rectangle instead of sweeping squares")
+	height := flag.Int("height", 0, "board height, defaults to -width")
+	minLength := flag.Int("minlen", 3, "shortest entry allowed")
+	maxLength := flag.Int("maxlen", 0, "longest entry allowed, 0 for no limit")
+	topologyFlag := flag.String("topology", "flat", "which edges wrap around, flat, cylinder for the left and right edges or torus for all of them")
+	shapeFile := flag.String("shape", "", "file with a board shape to enumerate instead of a size, a line per row with . for squares outside it")
+	symmetryFlag := flag.String("sym", "rot180,lr", "symmetries every board has, a comma separated list of rot90, rot180, lr, tb, diag and antidiag, or none")
+	flag.IntVar(&bounds.MinBlacks, "minblacks", 0, "fewest black squares allowed")
+	flag.IntVar(&bounds.MaxBlacks, "maxblacks", 0, "most black squares allowed, 0 for no limit")
+	flag.IntVar(&bounds.MinWords, "minwords", 0, "fewest words allowed")
+	flag.IntVar(&bounds.MaxWords, "maxwords", 0, "most words allowed, 0 for no limit")
+	flag.IntVar(&maxCheaters, "maxcheaters", -1, "most cheaters allowed, black squares that don't change the word count, -1 for no limit")
+	components := flag.Int("components", 1, "how many separate pieces the white squares have to be in, -1 for any number")
+	blackRegions := flag.Int("blackregions", 0, "how many separate pieces the black squares have to be in, 0 for any number")
+	blackBorder := flag.Bool("border", false, "count the edge of the board as one piece of black squares along with everything touching it, so -blackregions 1 means every black square connects to the edge")
+	biconnected := flag.Bool("biconnected", false, "only allow boards where no one white square splits the white squares it's with into more pieces")
+	forbidFile := flag.String("forbid", "", "file with patterns that can't show up anywhere on a board, separated by blank lines, with # for black, . for white and ? for either")
+	motifFile := flag.String("motif", "", "file with a pattern that has to show up on every board, like the ones for -forbid")
+	motifCount := flag.Int("motifcount", 0, "how many times the motif has to show up, 0 for at least once")
+	partialFile := flag.String("partial", "", "file with a board that has some squares filled in already, like a -forbid pattern the size of the board with ? for the empty squares, to enumerate the boards that fill in the rest")
+	cpuProfile := flag.String("cpuprofile", "", "write a CPU profile to this file")
+	flag.Parse()
+	if *step < 1 {
+		fmt.Printf("-step has to be at least 1, got %v\n", *step)
+		os.Exit(1)
+	}
+	symmetry, err := ParseSymmetry(*symmetryFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	for i := 0; i < BoardSize; i++ {
-		for j := 0; j < BoardSize; j++ {
-			visited[i][j] = false
+	topology, err := ParseTopology(*topologyFlag)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if *to < *from {
+		*to = *from
+	}
+	if *height == 0 {
+		*height = *width
+	}
+	base := Config{MinLength: *minLength, MaxLength: *maxLength, Symmetry: symmetry, Topology: topology, Components: *components, BlackRegions: *blackRegions, BlackBorder: *blackBorder, Biconnected: *biconnected, MotifCount: *motifCount}
+	if *forbidFile != "" {
+		text, err := os.ReadFile(*forbidFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		base.Forbidden, err = ParsePatterns(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
 	}
-	var dfs func(i, j int)
-	dfs = func(i, j int) {
-		if i < 0 || j < 0 || i >= BoardSize || j >= BoardSize {
-			return
+	if *motifFile != "" {
+		text, err := os.ReadFile(*motifFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		// it is black, do not consider
-		if grid[i][j] {
-			return
+		base.Motif, err = ParseMotif(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		if visited[i][j] {
-			return
+	}
+	if *partialFile != "" {
+		text, err := os.ReadFile(*partialFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		base.Partial, err = ParsePartial(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		visited[i][j] = true
-		dfs(i, j+1)
-		dfs(i, j-1)
-		dfs(i+1, j)
-		dfs(i-1, j)
 	}
-	done := false
-	for i := 0; i < BoardSize; i++ {
-		for j := 0; j < BoardSize; j++ {
-			if !grid[i][j] && !visited[i][j] {
-				if done {
-					return false
-				}
-				dfs(i, j)
-				done = true
-			}
+	var configs []Config
+	if *shapeFile != "" {
+		text, err := os.ReadFile(*shapeFile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		cfg := base
+		cfg.Shape, err = ParseShape(string(text))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		cfg.Width, cfg.Height = len(cfg.Shape[0]), len(cfg.Shape)
+		configs = append(configs, cfg)
+	} else if *width > 0 {
+		cfg := base
+		cfg.Width, cfg.Height = *width, *height
+		configs = append(configs, cfg)
+	} else if base.Partial != nil {
+		// the partial board is the size of the board
+		cfg := base
+		cfg.Width, cfg.Height = len(cfg.Partial[0]), len(cfg.Partial)
+		configs = append(configs, cfg)
+	} else {
+		for size := *from; size <= *to; size += *step {
+			cfg := base
+			cfg.Width, cfg.Height = size, size
+			configs = append(configs, cfg)
 		}
 	}
-	return true
-}
 
-func main() {
 	// bit 1 represents black, 0 represents white
 
 	// want a map from AllRows to a bitarray of things that work for that pairing
 	// 100 110 111 001 can't be a 1 in 100 or x10
 	// 1 is black, 0 is white
 	// 1, then other bit must
-	if Profile {
-		f, _ := os.Create("profile_stuff.prof")
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 		defer f.Close()
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
 
-	getNextValues := func(a, b, c uint32) []uint32 {
-		if c == MaxRow {
-			return []uint32{MaxRow}
+	for _, cfg := range configs {
+		if err := Init(cfg); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		poss := AvoidOneOneBitarrayMap[(a & ^c)|(b & ^c)]
-		if b & ^c == 0 && a & ^b & ^c == 0 {
-			poss.SetBit(MaxRow)
+		fmt.Printf("DONE! %vx%v total %v\n", cfg.Width, cfg.Height, enumerate())
+	}
+}
+
+// enumerate counts every board for whatever size cross was last
+// initialized with
+func enumerate() uint64 {
+	if BoardStrategy == StrategySearch {
+		return enumerateBySearch()
+	}
+
+	// below has the rows underneath the one we're picking, nearest first,
+	// going back far enough to see any run that's still too short. key
+	// gets the columns that can't have a black square yet
+	shortRunKey := func(below []Row) Row {
+		var key, white Row
+		for s := 1; s < len(below); s++ {
+			white = white.Or(below[s-1])
+			key = key.Or(below[s].AndNot(white))
 		}
-		return poss.ToNums()
+		return key
 	}
 
-	getNextValuesForTopRow := func(a, b, c uint32) []uint32 {
-		if c == MaxRow {
-			return []uint32{MaxRow}
+	// forced has the columns that would get a run longer than MaxLength
+	// without a black square here, and the ones outside the shape
+	// Going out from the middle, nothing past an all-black row can connect
+	// back, so the rest have to be all black too. That's unless the board
+	// is a torus, where they can wrap around instead, or the white squares
+	// don't have to all connect up
+	torus := BoardTopology == Torus
+	splits := Folded() && (torus || Components != 1)
+	getNextValues := func(below []Row, forced Row) []Row {
+		if below[0] == MaxRow && Folded() && !splits {
+			return []Row{MaxRow}
 		}
-		poss := AvoidOneOneBitarrayMap[(a & ^c)|(b & ^c)].And(AvoidOneZeroBitArrayMap[c])
-		if b & ^c == 0 && a & ^b & ^c == 0 {
-			poss.SetBit(MaxRow)
+		key := shortRunKey(below)
+		poss := AvoidOneOne(key).And(AvoidOneZero(forced))
+		ret := ToRows(poss)
+		if key.IsZero() {
+			ret = append(ret, MaxRow)
 		}
-		return poss.ToNums()
+		return ret
+	}
+
+	getNextValuesForTopRow := func(below []Row, forced Row) []Row {
+		if below[0] == MaxRow && Folded() && !splits {
+			return []Row{MaxRow}
+		}
+		key := shortRunKey(below)
+		poss := AvoidOneOne(key).And(AvoidOneZero(below[0].Or(forced)))
+		ret := ToRows(poss)
+		if key.IsZero() {
+			ret = append(ret, MaxRow)
+		}
+		return ret
+	}
+
+	// mostBlacks[r] and mostWords[r] are the most black squares and words
+	// the rows above row r can add to a board. None of the down entries
+	// get counted until the end, so they're all in mostWords
+	mostBlacks := make([]int, HalfHeight)
+	mostWords := make([]int, HalfHeight)
+	mostWords[0] = Width * MostEntries(Height)
+	for r := 1; r < HalfHeight; r++ {
+		copies := Copies(HalfHeight - r)
+		mostBlacks[r] = mostBlacks[r-1] + copies*MaxRow.AndNot(Mask[r-1]).OnesCount()
+		mostWords[r] = mostWords[r-1] + copies*MostEntries(Width)
 	}
 
 	startTime := time.Now()
 	var totalCount uint64 = 0
 	search := func(wg *sync.WaitGroup, thread_id int) {
 		defer wg.Done()
-		var board [HalfBoardSize]uint32
-		var grid [BoardSize][BoardSize]bool
-		var visited [BoardSize][BoardSize]bool
-		var recurse func(curIndex int)
+		board := make([]Row, HalfHeight)
+		below := make([]Row, MinLength)
+		full := make([]Row, Height)
+		var recurse func(curIndex, blacks, words int)
 		midCount := 0
-		getIndex := func(board [HalfBoardSize]uint32, idx int) uint32 {
-			if idx < HalfBoardSize {
+		getIndex := func(board []Row, idx int, curIndex int) Row {
+			if idx < HalfHeight {
 				return board[idx]
+			} else if !Folded() || Height-1-idx <= curIndex {
+				// the opposite of this row hasn't been picked yet (or it's
+				// off the bottom of the board), and pretending it's white
+				// never rules anything out
+				return Row{}
 			} else {
-				return RevMap[board[BoardSize-1-idx]]
+				return Opposite(board[Height-1-idx])
+			}
+		}
+
+		getForced := func(board []Row, curIndex int) Row {
+			// only once all of the MaxLength rows below are known, since
+			// the ones that aren't can't be pretended white here
+			lastIndex := curIndex + MaxLength
+			known := lastIndex < HalfHeight || Folded() && Height-1-lastIndex > curIndex
+			if MaxLength == 0 || !known {
+				return Row{}
+			}
+			forced := MaxRow
+			for s := 1; s <= MaxLength; s++ {
+				forced = forced.AndNot(getIndex(board, curIndex+s, curIndex))
 			}
+			return forced
 		}
 
-		recurse = func(curIndex int) {
-			// curIndex starts at middle rows then goes down to 0
+		// rowAt gets row i of the board, as long as it's one of the
+		// rows placed so far or the opposite of one
+		rowAt := func(i int) Row {
+			if i < HalfHeight {
+				return board[i]
+			}
+			return Opposite(board[Height-1-i])
+		}
+
+		// place puts row in as row curIndex and carries on, unless the
+		// board can't end up in bounds any more, it finishes a forbidden
+		// pattern or it doesn't agree with the squares that are filled in
+		// already. blacks and words are what the rows below have, where
+		// words only has across entries
+		place := func(curIndex int, row Row, blacks, words int) {
+			if !FitsPartial(row, curIndex) {
+				return
+			}
+			copies := Copies(HalfHeight - 1 - curIndex)
+			blacks += copies * row.AndNot(Mask[curIndex]).OnesCount()
+			words += copies * RowEntries(row)
+			if !bounds.CanReach(blacks, words, mostBlacks[curIndex], mostWords[curIndex]) {
+				return
+			}
+			board[curIndex] = row
+			if PatternHeight > 0 {
+				// the rows from curIndex down are all there, and its
+				// opposite finishes any patterns in the bottom half
+				last := Height - 1
+				if Folded() {
+					last = Height - 1 - curIndex
+				}
+				if ForbiddenFrom(curIndex, last, rowAt) || Folded() && ForbiddenTo(curIndex, last, rowAt) {
+					return
+				}
+			}
+			recurse(curIndex-1, blacks, words)
+		}
+
+		recurse = func(curIndex, blacks, words int) {
+			// curIndex starts at middle rows (or the bottom row) then goes
+			// down to 0
 			switch {
 			case curIndex == -1:
-				good := isConnected(board, &grid, &visited)
+				// the pruning only looks at rows that are already placed, so
+				// runs through the middle still need checking
+				good := HasValidRuns(board) && HasComponents(WhiteComponents(board))
+				if good {
+					for i := 0; i < Height; i++ {
+						full[i] = getIndex(board, i, curIndex)
+					}
+					good = !HasBlackBorder(full)
+				}
+				if good && bounds.Words() {
+					good = bounds.CanReach(blacks, BoardEntries(full), 0, 0)
+				} else if good {
+					good = bounds.CanReach(blacks, 0, 0, 0)
+				}
+				if good && maxCheaters >= 0 {
+					good = len(Cheaters(full)) <= maxCheaters
+				}
+				if good && PatternHeight > 0 {
+					good = !ForbiddenAcrossEdge(rowAt)
+				}
+				if good && TracksBlackRegions() {
+					good = HasBlackRegions(BoardBlackRegions(full))
+				}
+				if good && Biconnected {
+					good = len(ArticulationCells(full)) == 0
+				}
+				if good && HasMotif() {
+					good = HasMotifs(BoardMotifs(full))
+				}
 				if good {
 					atomic.AddUint64(&totalCount, 1)
-					// fmt.Printf("board %v\n", BoardToString(board))
 				}
-			case curIndex == HalfBoardSize-1:
-				for idx, middleRow := range PossMiddleRows {
-					if idx%NumThreads == thread_id {
-						fmt.Printf(
-							"thread %02d start middle %0*b of %v time %v\n",
+			case curIndex == HalfHeight-1:
+				// with an even height the middle pair of rows are just
+				// each other's opposites. Going from the bottom, it can be
+				// all black too
+				middleRows := PossMiddleRows
+				if !Folded() {
+					middleRows = append(AllRows[:len(AllRows):len(AllRows)], MaxRow)
+				} else if Height%2 == 0 {
+					middleRows = AllRows
+				}
+				if splits {
+					middleRows = append(middleRows[:len(middleRows):len(middleRows)], MaxRow)
+				}
+				for idx, middleRow := range middleRows {
+					if idx%NumThreads == thread_id && FitsMask(middleRow, curIndex) {
+						fmt.Fprintf(
+							os.Stderr,
+							"thread %02d start middle %v of %v time %v\n",
 							thread_id,
-							BoardSize,
-							middleRow,
-							len(PossMiddleRows),
+							RowToString(middleRow),
+							len(middleRows),
 							time.Since(startTime),
 						)
-						board[curIndex] = middleRow
-						recurse(curIndex - 1)
+						place(curIndex, middleRow, blacks, words)
 					}
 				}
-			case curIndex == HalfBoardSize-2:
-				possNextRows := PossFromMiddleMap[board[HalfBoardSize-1]].ToNums()
+			case curIndex == HalfHeight-2 && Height%2 == 1 && Folded():
+				// with 1-long entries the middle row can be the only one
+				// with any white squares
+				possNextRows := append(PossFromMiddle(board[HalfHeight-1]), MaxRow)
 				for _, nextRow := range possNextRows {
+					if !FitsMask(nextRow, curIndex) {
+						continue
+					}
 					midCount += 1
-					fmt.Printf(
-						"    thread %02d start middle %0*b %v of %v time %v\n",
+					fmt.Fprintf(
+						os.Stderr,
+						"    thread %02d start middle %v %v of %v time %v\n",
 						thread_id,
-						BoardSize,
-						nextRow,
+						RowToString(nextRow),
 						midCount,
 						len(possNextRows),
 						time.Since(startTime),
 					)
-					board[curIndex] = nextRow
-					recurse(curIndex - 1)
-				}
-			case curIndex < 2:
-				possNextRows := getNextValuesForTopRow(
-					getIndex(board, curIndex+3),
-					getIndex(board, curIndex+2),
-					getIndex(board, curIndex+1),
-				)
+					place(curIndex, nextRow, blacks, words)
+				}
+			case curIndex < MinLength-1 && !torus:
+				// runs this close to the top have to go all the way up,
+				// unless there's no top edge
+				for s := range below {
+					below[s] = getIndex(board, curIndex+1+s, curIndex)
+				}
+				possNextRows := getNextValuesForTopRow(below, getForced(board, curIndex).Or(Mask[curIndex]))
 				for _, nextRow := range possNextRows {
-					board[curIndex] = nextRow
-					recurse(curIndex - 1)
+					place(curIndex, nextRow, blacks, words)
 				}
 			default:
-				possNextRows := getNextValues(
-					getIndex(board, curIndex+3),
-					getIndex(board, curIndex+2),
-					getIndex(board, curIndex+1),
-				)
+				for s := range below {
+					below[s] = getIndex(board, curIndex+1+s, curIndex)
+				}
+				possNextRows := getNextValues(below, getForced(board, curIndex).Or(Mask[curIndex]))
 				for _, nextRow := range possNextRows {
-					board[curIndex] = nextRow
-					recurse(curIndex - 1)
+					place(curIndex, nextRow, blacks, words)
 				}
 			}
 		}
-		recurse(HalfBoardSize - 1)
+		recurse(HalfHeight-1, 0, 0)
 	}
 	var wg sync.WaitGroup
 	wg.Add(NumThreads)
@@ -183,5 +413,45 @@ func main() {
 		go search(&wg, thread_id)
 	}
 	wg.Wait()
-	fmt.Printf("DONE! total %v\n", totalCount)
+	return totalCount
+}
+
+// enumerateBySearch counts boards for symmetries that don't work row by
+// row
+func enumerateBySearch() uint64 {
+	var totalCount uint64 = 0
+	var wg sync.WaitGroup
+	wg.Add(NumThreads)
+	for thread_id := 0; thread_id < NumThreads; thread_id++ {
+		go func(thread_id int) {
+			defer wg.Done()
+			SearchBoards(thread_id, NumThreads, func(board []Row) {
+				if HasBlackBorder(board) {
+					return
+				}
+				blacks, words := 0, 0
+				for r := 0; r < Height; r++ {
+					blacks += board[r].AndNot(Mask[r]).OnesCount()
+				}
+				if bounds.Words() {
+					words = BoardEntries(board)
+				}
+				if !bounds.CanReach(blacks, words, 0, 0) {
+					return
+				}
+				if maxCheaters >= 0 && len(Cheaters(board)) > maxCheaters {
+					return
+				}
+				if PatternHeight > 0 && HasForbidden(board) {
+					return
+				}
+				if !HasMotifs(BoardMotifs(board)) {
+					return
+				}
+				atomic.AddUint64(&totalCount, 1)
+			})
+		}(thread_id)
+	}
+	wg.Wait()
+	return totalCount
 }
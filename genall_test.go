@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	. "./cross"
+)
+
+func TestEnumerateMatchesBruteForce(t *testing.T) {
+	for _, c := range bruteCases {
+		t.Run(c.name, func(t *testing.T) {
+			bounds, maxCheaters = c.bounds, c.maxCheaters
+			if err := Init(c.cfg); err != nil {
+				t.Fatal(err)
+			}
+			want := uint64(len(bruteBoards(c.cfg, c.bounds, c.maxCheaters)))
+			if got := enumerate(); got != want {
+				t.Errorf("got %v boards, brute force found %v", got, want)
+			}
+		})
+	}
+}